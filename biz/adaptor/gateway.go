@@ -0,0 +1,441 @@
+package adaptor
+
+import (
+	"context"
+	"errors"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/application/service"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/CloudStriver/platform/biz/infrastructure/sort"
+	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/basic"
+	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform"
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"github.com/bytedance/sonic"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// GatewayPrefix IDL 中还没有对应 RPC 的接口的路径前缀，均为 POST，请求体与响应体都是 JSON
+// 请求头中以 rpc-persist- 或 rpc-transit- 开头的字段与 kitex metainfo 一样传给服务，例如 Rpc-Persist-Comment-Sort-Mode
+// 调用方身份取自 Rpc-Persist-User-Id，需由前置的鉴权网关设置并覆盖客户端传入的同名请求头
+const GatewayPrefix = "/platform/"
+
+// gatewayError 接口出错时的响应体，Code 与 RPC 返回的错误码一致
+type gatewayError struct {
+	Code uint32 `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// NewGatewayHandler 返回与 kitex 服务并行提供的 HTTP 接口
+func NewGatewayHandler(s *PlatformServerImpl) http.Handler {
+	mux := http.NewServeMux()
+	for path, handler := range s.gatewayRoutes() {
+		mux.Handle(GatewayPrefix+path, handler)
+	}
+	return mux
+}
+
+func (s *PlatformServerImpl) gatewayRoutes() map[string]http.Handler {
+	return map[string]http.Handler{
+		"reconcile":              handleJSON(s.Reconcile),
+		"comment/edit":           handleJSON(s.EditComment),
+		"comment/revisions":      handleJSON(s.GetCommentRevisions),
+		"comment/tree":           handleJSON(s.GetCommentTree),
+		"comment/chain":          handleJSON(s.GetCommentChain),
+		"reaction/set":           handleJSON(s.React),
+		"reaction/unset":         handleJSON(s.Unreact),
+		"reaction/get":           handleJSON(s.GetCommentReactions),
+		"comment/search":         handleJSON(s.SearchComments),
+		"comment/changes":        handleJSON(s.GetCommentChanges),
+		"comment/batch":          handleJSON(s.GetCommentsInBatch),
+		"comment/list":           handleJSON(s.GetCommentListByFilter),
+		"comment/restore":        handleJSON(s.RestoreComment),
+		"moderation/moderate":    handleJSON(s.ModerateComment),
+		"moderation/queue":       handleJSON(s.GetModerationQueue),
+		"moderation/logs":        handleJSON(s.GetModerationLogs),
+		"subject/pre-moderation": handleJSON(s.SetPreModeration),
+		"pin/set":                handleJSON(s.PinComment),
+		"pin/unset":              handleJSON(s.UnpinComment),
+		"pin/reorder":            handleJSON(s.ReorderPins),
+		"subject/delete":         handleJSON(s.DeleteSubject),
+		"subject/delete/status":  handleJSON(s.GetDeleteStatus),
+	}
+}
+
+// handleJSON 把请求体解码为 Req 后调用 fn，并把 fn 的返回值编码为响应体
+func handleJSON[Req, Resp any](fn func(ctx context.Context, req *Req) (Resp, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		req := new(Req)
+		if err := sonic.ConfigDefault.NewDecoder(r.Body).Decode(req); err != nil {
+			writeJSON(w, http.StatusBadRequest, &gatewayError{Msg: err.Error()})
+			return
+		}
+		ctx := metainfo.FromHTTPHeader(r.Context(), metainfo.HTTPHeader(r.Header))
+		resp, err := fn(ctx, req)
+		if err != nil {
+			writeGatewayError(ctx, w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// writeGatewayError 业务错误按错误码返回，数据不存在与 id 无效返回 404，其他错误返回 500
+func writeGatewayError(ctx context.Context, w http.ResponseWriter, err error) {
+	st, ok := status.FromError(err)
+	switch {
+	case !ok:
+		log.CtxError(ctx, "处理接口请求 失败[%v]\n", err)
+		writeJSON(w, http.StatusInternalServerError, &gatewayError{Msg: err.Error()})
+	case errors.Is(err, consts.ErrNotFound) || errors.Is(err, consts.ErrInvalidId):
+		writeJSON(w, http.StatusNotFound, &gatewayError{Code: uint32(st.Code()), Msg: st.Message()})
+	default:
+		writeJSON(w, http.StatusBadRequest, &gatewayError{Code: uint32(st.Code()), Msg: st.Message()})
+	}
+}
+
+// emptyResp 没有返回数据的接口的响应体
+type emptyResp struct{}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	data, err := sonic.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
+
+// ReconcileReq 校对计数的请求，SubjectId 为空时分批校对全部评论区
+type ReconcileReq struct {
+	SubjectId string `json:"subjectId"`
+	Repair    bool   `json:"repair"`
+	BatchSize int64  `json:"batchSize"`
+}
+
+// Reconcile 校对评论区与一级评论的计数，Repair 为 true 时修正偏差
+func (s *PlatformServerImpl) Reconcile(ctx context.Context, req *ReconcileReq) (*service.ReconcileReport, error) {
+	if req.SubjectId != "" {
+		return s.ReconcileService.ReconcileSubject(ctx, req.SubjectId, req.Repair)
+	}
+	return s.ReconcileService.ReconcileAll(ctx, req.BatchSize, req.Repair)
+}
+
+// EditCommentReq 修改评论内容的请求，只有评论作者可以编辑，作者身份取自请求头 Rpc-Persist-User-Id
+type EditCommentReq struct {
+	CommentId string `json:"commentId"`
+	Content   string `json:"content"`
+}
+
+// EditComment 返回编辑后的评论
+func (s *PlatformServerImpl) EditComment(ctx context.Context, req *EditCommentReq) (*service.CommentDetail, error) {
+	return s.CommentService.EditComment(ctx, req.CommentId, service.UserIdFromContext(ctx), req.Content)
+}
+
+// GetCommentRevisionsReq 分页获取评论历史版本的请求
+type GetCommentRevisionsReq struct {
+	CommentId  string                   `json:"commentId"`
+	Pagination *basic.PaginationOptions `json:"pagination"`
+}
+
+func (s *PlatformServerImpl) GetCommentRevisions(ctx context.Context, req *GetCommentRevisionsReq) (*service.GetCommentRevisionsResp, error) {
+	return s.CommentService.GetCommentRevisions(ctx, req.CommentId, req.Pagination)
+}
+
+// GetCommentTreeReq 获取一级评论回复树的请求，MaxDepth 与 MaxBreadth 不大于 0 时使用默认值
+type GetCommentTreeReq struct {
+	RootId     string `json:"rootId"`
+	MaxDepth   int64  `json:"maxDepth"`
+	MaxBreadth int64  `json:"maxBreadth"`
+}
+
+func (s *PlatformServerImpl) GetCommentTree(ctx context.Context, req *GetCommentTreeReq) (*service.CommentNode, error) {
+	return s.CommentService.GetCommentTree(ctx, req.RootId, req.MaxDepth, req.MaxBreadth)
+}
+
+// GetCommentChainReq 获取从一级评论到指定评论的回复链的请求
+type GetCommentChainReq struct {
+	CommentId string `json:"commentId"`
+	MaxDepth  int64  `json:"maxDepth"`
+}
+
+// GetCommentChainResp 回复链，从一级评论开始排列
+type GetCommentChainResp struct {
+	Comments []*service.CommentDetail `json:"comments"`
+}
+
+func (s *PlatformServerImpl) GetCommentChain(ctx context.Context, req *GetCommentChainReq) (*GetCommentChainResp, error) {
+	chain, err := s.CommentService.GetCommentChain(ctx, req.CommentId, req.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+	return &GetCommentChainResp{Comments: chain}, nil
+}
+
+// ReactReq 设置或取消用户对评论的表态的请求，取消时忽略 Kind
+type ReactReq struct {
+	CommentId string `json:"commentId"`
+	UserId    string `json:"userId"`
+	Kind      int64  `json:"kind"`
+}
+
+func (s *PlatformServerImpl) React(ctx context.Context, req *ReactReq) (*emptyResp, error) {
+	if err := s.CommentService.React(ctx, req.CommentId, req.UserId, req.Kind); err != nil {
+		return nil, err
+	}
+	return &emptyResp{}, nil
+}
+
+func (s *PlatformServerImpl) Unreact(ctx context.Context, req *ReactReq) (*emptyResp, error) {
+	if err := s.CommentService.Unreact(ctx, req.CommentId, req.UserId); err != nil {
+		return nil, err
+	}
+	return &emptyResp{}, nil
+}
+
+// GetCommentReactionsReq 批量获取评论表态的请求，UserId 为空时不返回当前用户的表态
+type GetCommentReactionsReq struct {
+	UserId     string   `json:"userId"`
+	CommentIds []string `json:"commentIds"`
+}
+
+// GetCommentReactionsResp 与请求中 CommentIds 顺序一致的表态信息
+type GetCommentReactionsResp struct {
+	Reactions []*service.CommentReactions `json:"reactions"`
+}
+
+func (s *PlatformServerImpl) GetCommentReactions(ctx context.Context, req *GetCommentReactionsReq) (*GetCommentReactionsResp, error) {
+	reactions, err := s.CommentService.GetCommentReactions(ctx, req.UserId, req.CommentIds)
+	if err != nil {
+		return nil, err
+	}
+	return &GetCommentReactionsResp{Reactions: reactions}, nil
+}
+
+// SearchCommentsReq 按关键词搜索评论的请求，过滤条件为空时不限制，CreateAt 为毫秒时间戳
+type SearchCommentsReq struct {
+	Keyword      string                   `json:"keyword"`
+	SubjectId    *string                  `json:"subjectId"`
+	UserId       *string                  `json:"userId"`
+	States       []int64                  `json:"states"`
+	CreateAtFrom *int64                   `json:"createAtFrom"`
+	CreateAtTo   *int64                   `json:"createAtTo"`
+	Pagination   *basic.PaginationOptions `json:"pagination"`
+}
+
+func (s *PlatformServerImpl) SearchComments(ctx context.Context, req *SearchCommentsReq) (*service.SearchCommentsResp, error) {
+	return s.CommentService.SearchComments(ctx, req.Keyword, &commentMapper.EsFilterOptions{
+		OnlySubjectId: req.SubjectId,
+		OnlyUserId:    req.UserId,
+		OnlyStates:    req.States,
+		CreateAtFrom:  req.CreateAtFrom,
+		CreateAtTo:    req.CreateAtTo,
+	}, req.Pagination)
+}
+
+// GetCommentChangesReq 增量拉取评论区变更的请求，Seq 为 0 时按 Since（毫秒）开始拉取
+type GetCommentChangesReq struct {
+	SubjectId string `json:"subjectId"`
+	Seq       int64  `json:"seq"`
+	Since     int64  `json:"since"`
+	Limit     int64  `json:"limit"`
+}
+
+func (s *PlatformServerImpl) GetCommentChanges(ctx context.Context, req *GetCommentChangesReq) (*service.CommentChanges, error) {
+	return s.CommentService.GetCommentChanges(ctx, req.SubjectId, req.Seq, req.Since, req.Limit)
+}
+
+// GetCommentsInBatchReq 按 id 批量获取评论的请求，返回结果与 CommentIds 的顺序一致
+type GetCommentsInBatchReq struct {
+	CommentIds []string `json:"commentIds"`
+}
+
+func (s *PlatformServerImpl) GetCommentsInBatch(ctx context.Context, req *GetCommentsInBatchReq) (*service.GetCommentsInBatchResp, error) {
+	return s.CommentService.GetCommentsInBatch(ctx, req.CommentIds)
+}
+
+// GetCommentListByFilterReq 按条件分页获取评论的请求，条件为空时不限制，排序方式与 GetCommentList 一样由 metainfo 指定
+type GetCommentListByFilterReq struct {
+	UserId          *string `json:"userId"`
+	AtUserId        *string `json:"atUserId"`
+	SubjectId       *string `json:"subjectId"`
+	RootId          *string `json:"rootId"`
+	FatherId        *string `json:"fatherId"`
+	State           *int64  `json:"state"`
+	Attrs           *int64  `json:"attrs"`
+	MentionedUserId *string `json:"mentionedUserId"`
+	// Labels 带有其中任一标签，AllLabels 同时带有全部标签
+	Labels    []string `json:"labels"`
+	AllLabels []string `json:"allLabels"`
+	Type      *int64   `json:"type"`
+	// CreateAtFrom、CreateAtTo 创建时间范围，单位毫秒，左闭右开
+	CreateAtFrom *int64                   `json:"createAtFrom"`
+	CreateAtTo   *int64                   `json:"createAtTo"`
+	HasReplies   *bool                    `json:"hasReplies"`
+	Pagination   *basic.PaginationOptions `json:"pagination"`
+}
+
+func (s *PlatformServerImpl) GetCommentListByFilter(ctx context.Context, req *GetCommentListByFilterReq) (*service.CommentDetailList, error) {
+	return s.CommentService.GetCommentListByFilter(ctx, &commentMapper.FilterOptions{
+		OnlyUserId:          req.UserId,
+		OnlyAtUserId:        req.AtUserId,
+		OnlySubjectId:       req.SubjectId,
+		OnlyRootId:          req.RootId,
+		OnlyFatherId:        req.FatherId,
+		OnlyState:           req.State,
+		OnlyAttrs:           req.Attrs,
+		OnlyMentionedUserId: req.MentionedUserId,
+		OnlyLabels:          req.Labels,
+		AllLabels:           req.AllLabels,
+		OnlyType:            req.Type,
+		CreateAtFrom:        req.CreateAtFrom,
+		CreateAtTo:          req.CreateAtTo,
+		HasReplies:          req.HasReplies,
+	}, req.Pagination, sort.SortModeFromContext(ctx))
+}
+
+// RestoreCommentReq 从回收站恢复评论的请求，CommentId 为被删除的评论 id，随其一起删除的回复一并恢复
+type RestoreCommentReq struct {
+	CommentId string `json:"commentId"`
+}
+
+func (s *PlatformServerImpl) RestoreComment(ctx context.Context, req *RestoreCommentReq) (*emptyResp, error) {
+	if err := s.RecycleService.RestoreComment(ctx, req.CommentId); err != nil {
+		return nil, err
+	}
+	return &emptyResp{}, nil
+}
+
+// ModerateCommentReq 审核评论的请求，按审核状态机变更状态，操作人取自请求头 Rpc-Persist-User-Id
+type ModerateCommentReq struct {
+	CommentId string `json:"commentId"`
+	State     int64  `json:"state"`
+	Reason    string `json:"reason"`
+}
+
+func (s *PlatformServerImpl) ModerateComment(ctx context.Context, req *ModerateCommentReq) (*emptyResp, error) {
+	operatorId := service.UserIdFromContext(ctx)
+	if operatorId == "" {
+		return nil, consts.ErrIllegalOperation
+	}
+	if err := s.CommentService.ModerateComment(ctx, req.CommentId, operatorId, req.State, req.Reason); err != nil {
+		return nil, err
+	}
+	return &emptyResp{}, nil
+}
+
+// GetModerationQueueReq 分页获取审核队列的请求，State 为 0 时列出待审核评论，SubjectId 为空时不限评论区
+type GetModerationQueueReq struct {
+	SubjectId  *string                  `json:"subjectId"`
+	State      int64                    `json:"state"`
+	Pagination *basic.PaginationOptions `json:"pagination"`
+}
+
+func (s *PlatformServerImpl) GetModerationQueue(ctx context.Context, req *GetModerationQueueReq) (*service.CommentDetailList, error) {
+	return s.CommentService.GetModerationQueue(ctx, req.SubjectId, req.State, req.Pagination)
+}
+
+// GetModerationLogsReq 分页获取评论审核记录的请求
+type GetModerationLogsReq struct {
+	CommentId  string                   `json:"commentId"`
+	Pagination *basic.PaginationOptions `json:"pagination"`
+}
+
+func (s *PlatformServerImpl) GetModerationLogs(ctx context.Context, req *GetModerationLogsReq) (*service.GetModerationLogsResp, error) {
+	return s.CommentService.GetModerationLogs(ctx, req.CommentId, req.Pagination)
+}
+
+// SetPreModerationReq 开启或关闭评论区先审后发的请求
+type SetPreModerationReq struct {
+	SubjectId string `json:"subjectId"`
+	Enabled   bool   `json:"enabled"`
+}
+
+func (s *PlatformServerImpl) SetPreModeration(ctx context.Context, req *SetPreModerationReq) (*emptyResp, error) {
+	if err := s.SubjectService.SetPreModeration(ctx, req.SubjectId, req.Enabled); err != nil {
+		return nil, err
+	}
+	return &emptyResp{}, nil
+}
+
+// PinCommentReq 置顶或取消置顶一级评论的请求，ExpireAt 为过期时间（毫秒），为 0 表示永久置顶，取消置顶时忽略
+type PinCommentReq struct {
+	SubjectId string `json:"subjectId"`
+	CommentId string `json:"commentId"`
+	ExpireAt  int64  `json:"expireAt"`
+}
+
+func (s *PlatformServerImpl) PinComment(ctx context.Context, req *PinCommentReq) (*emptyResp, error) {
+	if err := s.CommentService.PinComment(ctx, req.SubjectId, req.CommentId, req.ExpireAt); err != nil {
+		return nil, err
+	}
+	return &emptyResp{}, nil
+}
+
+func (s *PlatformServerImpl) UnpinComment(ctx context.Context, req *PinCommentReq) (*emptyResp, error) {
+	if err := s.CommentService.UnpinComment(ctx, req.SubjectId, req.CommentId); err != nil {
+		return nil, err
+	}
+	return &emptyResp{}, nil
+}
+
+// ReorderPinsReq 重排置顶评论的请求，CommentIds 必须恰好是当前全部置顶评论
+type ReorderPinsReq struct {
+	SubjectId  string   `json:"subjectId"`
+	CommentIds []string `json:"commentIds"`
+}
+
+func (s *PlatformServerImpl) ReorderPins(ctx context.Context, req *ReorderPinsReq) (*emptyResp, error) {
+	if err := s.CommentService.ReorderPins(ctx, req.SubjectId, req.CommentIds); err != nil {
+		return nil, err
+	}
+	return &emptyResp{}, nil
+}
+
+// DeleteSubjectReq 删除评论区的请求，DryRun 为 true 时只统计将要删除的评论，不做任何修改
+type DeleteSubjectReq struct {
+	SubjectId string `json:"subjectId"`
+	DryRun    bool   `json:"dryRun"`
+}
+
+// DeleteSubject 试运行时同步统计并返回结果；否则与 DeleteCommentSubject 一样标记为删除中后由后台任务删除，
+// 返回标记后的删除状态，之后通过 subject/delete/status 查询进度
+func (s *PlatformServerImpl) DeleteSubject(ctx context.Context, req *DeleteSubjectReq) (*DeleteSubjectResp, error) {
+	if req.DryRun {
+		p, err := s.SubjectService.DeleteSubjectCascade(ctx, req.SubjectId, 0, true, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &DeleteSubjectResp{DryRun: p}, nil
+	}
+	if _, err := s.SubjectService.DeleteCommentSubject(ctx, &platform.DeleteCommentSubjectReq{SubjectId: req.SubjectId}); err != nil {
+		return nil, err
+	}
+	status, err := s.SubjectService.GetDeleteStatus(ctx, req.SubjectId)
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteSubjectResp{Status: status}, nil
+}
+
+// DeleteSubjectResp 试运行时返回 DryRun，否则返回 Status
+type DeleteSubjectResp struct {
+	DryRun *service.DeleteSubjectProgress `json:"dryRun,omitempty"`
+	Status *service.DeleteSubjectStatus   `json:"status,omitempty"`
+}
+
+// GetDeleteStatusReq 查询评论区后台删除进度的请求
+type GetDeleteStatusReq struct {
+	SubjectId string `json:"subjectId"`
+}
+
+func (s *PlatformServerImpl) GetDeleteStatus(ctx context.Context, req *GetDeleteStatusReq) (*service.DeleteSubjectStatus, error) {
+	return s.SubjectService.GetDeleteStatus(ctx, req.SubjectId)
+}
@@ -4,20 +4,30 @@ import (
 	"context"
 	"github.com/CloudStriver/platform/biz/application/service"
 	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/sensitive"
+	"github.com/CloudStriver/platform/biz/infrastructure/sort"
 	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform"
-	"github.com/zeromicro/go-zero/core/mr"
 )
 
 type PlatformServerImpl struct {
 	*config.Config
-	CommentService  service.ICommentService
-	LabelService    service.ILabelService
-	SubjectService  service.ISubjectService
-	RelationService service.RelationService
+	CommentService   service.ICommentService
+	LabelService     service.ILabelService
+	SubjectService   service.ISubjectService
+	RelationService  service.RelationService
+	ReconcileService service.IReconcileService
+	RecycleService   service.IRecycleService
+	OutboxService    service.IOutboxService
+	StreamService    service.IStreamService
+	IndexService     service.IIndexService
+	SensitiveFilter  *sensitive.Filter
 }
 
 func (s *PlatformServerImpl) GetCommentBlocks(ctx context.Context, req *platform.GetCommentBlocksReq) (res *platform.GetCommentBlocksResp, err error) {
-	return s.CommentService.GetCommentBlocks(ctx, req)
+	if commentId := service.AroundCommentIdFromContext(ctx); commentId != "" {
+		return s.CommentService.GetCommentBlocksAround(ctx, req, sort.SortModeFromContext(ctx), service.ReplyPreviewOptionsFromContext(ctx), commentId)
+	}
+	return s.CommentService.GetCommentBlocks(ctx, req, sort.SortModeFromContext(ctx), service.ReplyPreviewOptionsFromContext(ctx))
 }
 
 func (s *PlatformServerImpl) GetRelationPathsCount(ctx context.Context, req *platform.GetRelationPathsCountReq) (res *platform.GetRelationPathsCountResp, err error) {
@@ -61,46 +71,14 @@ func (c *PlatformServerImpl) GetComment(ctx context.Context, req *platform.GetCo
 }
 
 func (c *PlatformServerImpl) GetCommentList(ctx context.Context, req *platform.GetCommentListReq) (res *platform.GetCommentListResp, err error) {
-	return c.CommentService.GetCommentList(ctx, req)
+	if commentId := service.AroundCommentIdFromContext(ctx); commentId != "" {
+		return c.CommentService.GetCommentListAround(ctx, req, sort.SortModeFromContext(ctx), commentId)
+	}
+	return c.CommentService.GetCommentList(ctx, req, sort.SortModeFromContext(ctx))
 }
 
 func (c *PlatformServerImpl) CreateComment(ctx context.Context, req *platform.CreateCommentReq) (res *platform.CreateCommentResp, err error) {
-	if res, err = c.CommentService.CreateComment(ctx, req); err != nil {
-		return res, err
-	}
-
-	var getSubjectResp *platform.GetCommentSubjectResp
-	if getSubjectResp, err = c.SubjectService.GetCommentSubject(ctx, &platform.GetCommentSubjectReq{SubjectId: req.SubjectId}); err != nil {
-		return res, err
-	}
-
-	_ = mr.Finish(func() error {
-		if req.RootId != req.SubjectId {
-			if req.FatherId != req.SubjectId {
-				var getRootComment *platform.GetCommentResp
-				if getRootComment, err = c.CommentService.GetComment(ctx, &platform.GetCommentReq{CommentId: req.RootId}); err != nil {
-					return err
-				}
-				// 二级评论 + 三级评论
-				c.CommentService.UpdateCount(ctx, req.RootId, getRootComment.Count+1)
-			}
-		}
-		return nil
-	}, func() error {
-		if req.RootId == req.SubjectId {
-			// 一级评论
-			if req.FatherId == req.SubjectId {
-				c.SubjectService.UpdateCount(ctx, req.SubjectId, getSubjectResp.RootCount+1, getSubjectResp.AllCount+1)
-			}
-		} else {
-			// 二级评论 + 三级评论
-			if req.FatherId != req.SubjectId {
-				c.SubjectService.UpdateCount(ctx, req.SubjectId, getSubjectResp.RootCount, getSubjectResp.AllCount+1)
-			}
-		}
-		return nil
-	})
-	return res, nil
+	return c.CommentService.CreateComment(ctx, req)
 }
 
 func (c *PlatformServerImpl) UpdateComment(ctx context.Context, req *platform.UpdateCommentReq) (res *platform.UpdateCommentResp, err error) {
@@ -108,70 +86,11 @@ func (c *PlatformServerImpl) UpdateComment(ctx context.Context, req *platform.Up
 }
 
 func (c *PlatformServerImpl) DeleteComment(ctx context.Context, req *platform.DeleteCommentReq) (res *platform.DeleteCommentResp, err error) {
-	var (
-		getCommentResp *platform.GetCommentResp
-		getSubjectResp *platform.GetCommentSubjectResp
-	)
-
-	if getCommentResp, err = c.CommentService.GetComment(ctx, &platform.GetCommentReq{CommentId: req.CommentId}); err != nil {
-		return res, err
-	}
-
-	if getSubjectResp, err = c.SubjectService.GetCommentSubject(ctx, &platform.GetCommentSubjectReq{SubjectId: getCommentResp.SubjectId}); err != nil {
-		return res, err
-	}
-
-	if getCommentResp.RootId == getCommentResp.SubjectId {
-		// 一级评论
-		if getCommentResp.FatherId == getCommentResp.SubjectId {
-			if res, err = c.CommentService.DeleteComment(ctx, req.CommentId, getCommentResp.Type, true); err != nil {
-				return res, err
-			}
-		}
-	} else {
-		// 二级评论 + 三级评论
-		if getCommentResp.FatherId != getCommentResp.SubjectId {
-			if res, err = c.CommentService.DeleteComment(ctx, req.CommentId, getCommentResp.Type, false); err != nil {
-				return res, err
-			}
-		}
-	}
-
-	_ = mr.Finish(func() error {
-		if getCommentResp.RootId != getCommentResp.SubjectId {
-			if getCommentResp.FatherId != getCommentResp.SubjectId {
-				var getRootComment *platform.GetCommentResp
-				if getRootComment, err = c.CommentService.GetComment(ctx, &platform.GetCommentReq{CommentId: getCommentResp.RootId}); err != nil {
-					return err
-				}
-				// 二级评论 + 三级评论
-				c.CommentService.UpdateCount(ctx, getCommentResp.RootId, getRootComment.Count-1)
-			}
-		}
-		return nil
-	}, func() error {
-		if getCommentResp.RootId == getCommentResp.SubjectId {
-			// 一级评论
-			if getCommentResp.FatherId == getCommentResp.SubjectId {
-				c.SubjectService.UpdateCount(ctx, getCommentResp.SubjectId, getSubjectResp.RootCount-1, getSubjectResp.AllCount-getCommentResp.Count-1)
-			}
-		} else {
-			// 二级评论 + 三级评论
-			if getCommentResp.FatherId != getCommentResp.SubjectId {
-				c.SubjectService.UpdateCount(ctx, getCommentResp.SubjectId, getSubjectResp.RootCount, getSubjectResp.AllCount-1)
-			}
-		}
-		return nil
-	})
-	return res, nil
+	return c.CommentService.DeleteComment(ctx, req)
 }
 
 func (c *PlatformServerImpl) SetCommentAttrs(ctx context.Context, req *platform.SetCommentAttrsReq) (res *platform.SetCommentAttrsResp, err error) {
-	var resp *platform.GetCommentSubjectResp
-	if resp, err = c.SubjectService.GetCommentSubject(ctx, &platform.GetCommentSubjectReq{SubjectId: req.SubjectId}); err != nil {
-		return res, err
-	}
-	return c.CommentService.SetCommentAttrs(ctx, req, resp)
+	return c.CommentService.SetCommentAttrs(ctx, req)
 }
 
 func (c *PlatformServerImpl) GetCommentSubject(ctx context.Context, req *platform.GetCommentSubjectReq) (res *platform.GetCommentSubjectResp, err error) {
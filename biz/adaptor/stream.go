@@ -0,0 +1,109 @@
+package adaptor
+
+import (
+	"errors"
+	"fmt"
+	"github.com/CloudStriver/go-pkg/utils/pconvertor"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/event"
+	changeMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/change"
+	"github.com/bytedance/sonic"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StreamPath 订阅评论变更的 SSE 接口：GET /comment/stream?subjectId=xxx&seq=xxx
+const StreamPath = "/comment/stream"
+
+// streamEvents SSE 中的事件名
+var streamEvents = map[int64]string{
+	changeMapper.CreateOp:  "create",
+	changeMapper.EditOp:    "edit",
+	changeMapper.StateOp:   "state",
+	changeMapper.DeleteOp:  "delete",
+	changeMapper.RestoreOp: "restore",
+}
+
+// NewStreamHandler 返回与 kitex 服务并行提供的 SSE 接口
+func NewStreamHandler(s *PlatformServerImpl) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(StreamPath, s.ServeStream)
+	return mux
+}
+
+// ServeStream 推送评论区的评论变更，事件 id 为变更序号
+// 断线重连时浏览器携带的 Last-Event-ID 优先于 seq 参数，服务端从该序号之后继续推送
+func (s *PlatformServerImpl) ServeStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	subjectId := r.URL.Query().Get("subjectId")
+	seqStr := r.URL.Query().Get("seq")
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		seqStr = id
+	}
+	var seq int64
+	if seqStr != "" {
+		var err error
+		if seq, err = strconv.ParseInt(seqStr, 10, 64); err != nil || seq < 0 {
+			http.Error(w, "invalid seq", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	events, err := s.StreamService.Stream(ctx, subjectId, seq)
+	switch {
+	case errors.Is(err, consts.ErrNotFound) || errors.Is(err, consts.ErrInvalidId):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.Stream.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err = fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if err = writeEvent(w, ev); err != nil {
+				log.CtxError(ctx, "推送评论变更 失败[%v]\n", err)
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev *event.Event) error {
+	data, err := sonic.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, streamEvents[ev.Op], pconvertor.Bytes2String(data))
+	return err
+}
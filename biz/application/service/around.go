@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/pagination"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/convertor"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/CloudStriver/platform/biz/infrastructure/sort"
+	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform"
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"github.com/samber/lo"
+)
+
+// AroundCommentMetaKey 客户端通过 kitex metainfo 传递跳转目标评论 id 时使用的 key
+// 携带时 GetCommentList 与 GetCommentBlocks 返回包含该评论的一页，而不是第一页
+const AroundCommentMetaKey = "AROUND_COMMENT_ID"
+
+// AroundCommentIdFromContext 读取请求携带的跳转目标评论 id，未携带时返回空字符串
+func AroundCommentIdFromContext(ctx context.Context) string {
+	if id, ok := metainfo.GetValue(ctx, AroundCommentMetaKey); ok {
+		return id
+	}
+	id, _ := metainfo.GetPersistentValue(ctx, AroundCommentMetaKey)
+	return id
+}
+
+// GetCommentListAround 返回包含 commentId 的一页评论，用于从评论链接直接跳转
+// 列表为一级评论列表而目标是回复时，定位到其所属的一级评论；返回的 token 同时记录页首与页尾，
+// 以 Backward 为 false 继续向后翻页，为 true 向前翻页，与普通分页的 token 通用
+func (s *CommentService) GetCommentListAround(ctx context.Context, req *platform.GetCommentListReq, sortMode int64, commentId string) (resp *platform.GetCommentListResp, err error) {
+	resp = new(platform.GetCommentListResp)
+	filter := convertor.CommentFilterOptionsToFilterOptions(req.FilterOptions)
+	filter.ExcludeStates = []int64{consts.DeletedState}
+
+	var anchor *commentMapper.Comment
+	if anchor, err = s.findAnchor(ctx, commentId, lo.FromPtr(filter.OnlyRootId)); err != nil {
+		return resp, err
+	}
+	if anchor.State == consts.DeletedState || filter.OnlySubjectId != nil && anchor.SubjectId != *filter.OnlySubjectId {
+		return resp, consts.ErrNotFound
+	}
+	if err = s.checkAnchor(ctx, filter, anchor); err != nil {
+		return resp, err
+	}
+	var pinned []*commentMapper.Comment
+	if filter.OnlySubjectId != nil && lo.FromPtr(filter.OnlyRootId) == *filter.OnlySubjectId {
+		if pinned, filter.ExcludeCommentIds, err = s.findPinned(ctx, *filter.OnlySubjectId, filter); err != nil {
+			return resp, err
+		}
+	}
+	// 置顶评论只出现在第一页
+	if lo.Contains(filter.ExcludeCommentIds, anchor.ID.Hex()) {
+		req.Pagination = nil
+		return s.GetCommentList(ctx, req, sortMode)
+	}
+
+	var (
+		comments []*commentMapper.Comment
+		token    *string
+	)
+	p := convertor.ParsePagination(req.Pagination)
+	p.EnsureSafe()
+	if comments, token, err = s.findAround(ctx, filter, anchor, *p.Limit, sort.CommentCursorType(sortMode)); err != nil {
+		return resp, err
+	}
+	if resp.Total, err = s.CommentMongoMapper.Count(ctx, filter); err != nil {
+		log.CtxError(ctx, "获取评论数 失败[%v]\n", err)
+		return resp, err
+	}
+	resp.Total += int64(len(pinned))
+	resp.Token = *token
+	resp.Comments = lo.Map(comments, func(comment *commentMapper.Comment, _ int) *platform.Comment {
+		return convertor.CommentMapperToComment(comment)
+	})
+	return resp, nil
+}
+
+// GetCommentBlocksAround 返回包含 commentId 的一页评论块，token 的用法与 GetCommentListAround 相同
+// 目标是回复时，其所属一级评论的评论块中返回目标回复前后的回复，而不是普通的回复预览
+func (s *CommentService) GetCommentBlocksAround(ctx context.Context, req *platform.GetCommentBlocksReq, sortMode int64, replyOpts *ReplyPreviewOptions, commentId string) (resp *platform.GetCommentBlocksResp, err error) {
+	resp = new(platform.GetCommentBlocksResp)
+	replyOpts = replyOpts.withDefaults()
+
+	var target, anchor *commentMapper.Comment
+	if target, err = s.CommentMongoMapper.FindOne(ctx, commentId); err != nil {
+		log.CtxError(ctx, "获取评论详情 失败[%v]\n", err)
+		return resp, err
+	}
+	if target.SubjectId != req.SubjectId || lo.Contains(consts.InvisibleStates, target.State) {
+		return resp, consts.ErrNotFound
+	}
+	if anchor, err = s.findAnchor(ctx, commentId, req.RootId); err != nil {
+		return resp, err
+	}
+	replyFilter := &commentMapper.FilterOptions{OnlyRootId: lo.ToPtr(target.RootId), ExcludeStates: consts.InvisibleStates}
+	p := convertor.ParsePagination(req.Pagination)
+	p.EnsureSafe()
+
+	// 回复列表：只有一个评论块，返回目标回复前后的回复
+	if req.RootId != req.SubjectId {
+		replyList := &platform.ReplyList{}
+		if replyList.Comments, replyList.Token, replyList.Total, err = s.findRepliesAround(ctx, replyFilter, target, *p.Limit, replyOpts.SortMode); err != nil {
+			return resp, err
+		}
+		resp.CommentBlocks = []*platform.CommentBlock{{ReplyList: replyList}}
+		return resp, nil
+	}
+
+	filter := &commentMapper.FilterOptions{OnlyRootId: lo.ToPtr(req.RootId), ExcludeStates: consts.InvisibleStates, ExcludeEmptyTombstones: true}
+	if err = s.checkAnchor(ctx, filter, anchor); err != nil {
+		return resp, err
+	}
+	var pinned []*commentMapper.Comment
+	if pinned, filter.ExcludeCommentIds, err = s.findPinned(ctx, req.SubjectId, filter); err != nil {
+		return resp, err
+	}
+	var comments []*commentMapper.Comment
+	if lo.Contains(filter.ExcludeCommentIds, anchor.ID.Hex()) {
+		// 置顶评论只出现在第一页
+		if comments, resp.Total, err = s.CommentMongoMapper.FindManyAndCount(ctx, filter, p, sort.CommentCursorType(sortMode)); err != nil {
+			log.CtxError(ctx, "获取评论列表 失败[%v]\n", err)
+			return resp, err
+		}
+		comments = append(pinned, comments...)
+		if p.LastToken != nil {
+			resp.Token = *p.LastToken
+		}
+	} else {
+		var token *string
+		if comments, token, err = s.findAround(ctx, filter, anchor, *p.Limit, sort.CommentCursorType(sortMode)); err != nil {
+			return resp, err
+		}
+		if resp.Total, err = s.CommentMongoMapper.Count(ctx, filter); err != nil {
+			log.CtxError(ctx, "获取评论数 失败[%v]\n", err)
+			return resp, err
+		}
+		resp.Token = *token
+	}
+	resp.Total += int64(len(pinned))
+	if resp.CommentBlocks, err = s.buildCommentBlocks(ctx, comments, replyOpts); err != nil {
+		return resp, err
+	}
+	if target.RootId == target.SubjectId {
+		return resp, nil
+	}
+	for i, comment := range comments {
+		if comment.ID != anchor.ID {
+			continue
+		}
+		replyList := resp.CommentBlocks[i].ReplyList
+		if replyList.Comments, replyList.Token, replyList.Total, err = s.findRepliesAround(ctx, replyFilter, target, replyOpts.Size, replyOpts.SortMode); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// findAnchor 返回在 rootId 对应的列表中代表 commentId 的评论：目标本身，或列表为一级评论列表时目标所属的一级评论
+func (s *CommentService) findAnchor(ctx context.Context, commentId, rootId string) (*commentMapper.Comment, error) {
+	data, err := s.CommentMongoMapper.FindOne(ctx, commentId)
+	if err != nil {
+		log.CtxError(ctx, "获取评论详情 失败[%v]\n", err)
+		return nil, err
+	}
+	switch {
+	case rootId == "" || data.RootId == rootId:
+		return data, nil
+	case rootId == data.SubjectId:
+		if data, err = s.CommentMongoMapper.FindOne(ctx, data.RootId); err != nil {
+			log.CtxError(ctx, "获取一级评论 失败[%v]\n", err)
+			return nil, err
+		}
+		return data, nil
+	default:
+		return nil, consts.ErrIllegalOperation
+	}
+}
+
+// checkAnchor 确认定位的评论满足列表的筛选条件，不满足时普通分页也不会返回该评论，视为不存在
+// 需要在设置 ExcludeCommentIds 之前调用
+func (s *CommentService) checkAnchor(ctx context.Context, filter *commentMapper.FilterOptions, anchor *commentMapper.Comment) error {
+	id := anchor.ID.Hex()
+	if filter.OnlyCommentIds != nil && !lo.Contains(filter.OnlyCommentIds, id) {
+		return consts.ErrNotFound
+	}
+	f := *filter
+	f.OnlyCommentIds = []string{id}
+	count, err := s.CommentMongoMapper.Count(ctx, &f)
+	if err != nil {
+		log.CtxError(ctx, "获取评论数 失败[%v]\n", err)
+		return err
+	}
+	if count == 0 {
+		return consts.ErrNotFound
+	}
+	return nil
+}
+
+// findRepliesAround 返回目标回复前后的回复及其 token 与回复总数
+func (s *CommentService) findRepliesAround(ctx context.Context, filter *commentMapper.FilterOptions, target *commentMapper.Comment, limit, sortMode int64) (replies []*platform.Comment, token string, total int64, err error) {
+	var (
+		comments []*commentMapper.Comment
+		t        *string
+	)
+	if comments, t, err = s.findAround(ctx, filter, target, limit, sort.CommentCursorType(sortMode)); err != nil {
+		return nil, "", 0, err
+	}
+	if total, err = s.CommentMongoMapper.Count(ctx, filter); err != nil {
+		log.CtxError(ctx, "获取回复数 失败[%v]\n", err)
+		return nil, "", 0, err
+	}
+	return lo.Map(comments, func(comment *commentMapper.Comment, _ int) *platform.Comment {
+		return convertor.CommentMapperToComment(comment)
+	}), *t, total, nil
+}
+
+// findAround 按 sorter 的顺序返回 anchor 前后共 limit 条评论，anchor 尽量位于页中；前面不足时由后面补足
+func (s *CommentService) findAround(ctx context.Context, filter *commentMapper.FilterOptions, anchor *commentMapper.Comment, limit int64, sorter sort.MongoCursor) (page []*commentMapper.Comment, token *string, err error) {
+	if token, err = pagination.NewRawStore(sorter).StoreCursor(ctx, nil, anchor, anchor); err != nil {
+		return nil, nil, err
+	}
+	var before, after []*commentMapper.Comment
+	if n := (limit - 1) / 2; n > 0 {
+		if before, err = s.CommentMongoMapper.FindMany(ctx, filter, &pagination.PaginationOptions{
+			Limit:     lo.ToPtr(n),
+			Backward:  lo.ToPtr(true),
+			LastToken: lo.ToPtr(*token),
+		}, sorter); err != nil {
+			log.CtxError(ctx, "获取评论列表 失败[%v]\n", err)
+			return nil, nil, err
+		}
+	}
+	if n := limit - 1 - int64(len(before)); n > 0 {
+		if after, err = s.CommentMongoMapper.FindMany(ctx, filter, &pagination.PaginationOptions{
+			Limit:     lo.ToPtr(n),
+			Backward:  lo.ToPtr(false),
+			LastToken: lo.ToPtr(*token),
+		}, sorter); err != nil {
+			log.CtxError(ctx, "获取评论列表 失败[%v]\n", err)
+			return nil, nil, err
+		}
+	}
+	page = append(append(before, anchor), after...)
+	if token, err = pagination.NewRawStore(sorter).StoreCursor(ctx, nil, page[0], page[len(page)-1]); err != nil {
+		return nil, nil, err
+	}
+	return page, token, nil
+}
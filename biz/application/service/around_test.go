@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+// aroundMapper 只实现 Count，按用户与评论 id 筛选预先准备的评论
+type aroundMapper struct {
+	commentMapper.IMongoMapper
+	comments []*commentMapper.Comment
+}
+
+func (m *aroundMapper) Count(_ context.Context, filter *commentMapper.FilterOptions) (int64, error) {
+	return int64(lo.CountBy(m.comments, func(comment *commentMapper.Comment) bool {
+		return (filter.OnlyUserId == nil || comment.UserId == *filter.OnlyUserId) &&
+			(filter.OnlyCommentIds == nil || lo.Contains(filter.OnlyCommentIds, comment.ID.Hex()))
+	})), nil
+}
+
+func TestCheckAnchor(t *testing.T) {
+	anchor := &commentMapper.Comment{ID: primitive.NewObjectID(), UserId: "user"}
+	s := &CommentService{CommentMongoMapper: &aroundMapper{comments: []*commentMapper.Comment{anchor}}}
+	tests := []struct {
+		name    string
+		filter  *commentMapper.FilterOptions
+		wantErr error
+	}{
+		{name: "无额外筛选", filter: &commentMapper.FilterOptions{}},
+		{name: "满足用户筛选", filter: &commentMapper.FilterOptions{OnlyUserId: lo.ToPtr("user")}},
+		{name: "不满足用户筛选", filter: &commentMapper.FilterOptions{OnlyUserId: lo.ToPtr("other")}, wantErr: consts.ErrNotFound},
+		{name: "不在指定的评论中", filter: &commentMapper.FilterOptions{OnlyCommentIds: []string{primitive.NewObjectID().Hex()}}, wantErr: consts.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.checkAnchor(context.Background(), tt.filter, anchor); err != tt.wantErr {
+				t.Errorf("checkAnchor() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/samber/lo"
+)
+
+// maxCommentBatchSize 单次批量获取的评论 id 上限
+const maxCommentBatchSize = 100
+
+// BatchComment 批量获取评论时与请求 id 一一对应的结果
+type BatchComment struct {
+	CommentId string `json:"commentId"`
+	// Comment 评论不存在或已删除时为空
+	Comment  *CommentDetail `json:"comment,omitempty"`
+	NotFound bool           `json:"notFound"`
+	Deleted  bool           `json:"deleted"`
+}
+
+type GetCommentsInBatchResp struct {
+	Comments []*BatchComment `json:"comments"`
+}
+
+// GetCommentsInBatch 按 commentIds 的顺序返回评论，重复的 id 返回相同的结果，不存在或已删除的评论只做标记
+func (s *CommentService) GetCommentsInBatch(ctx context.Context, commentIds []string) (resp *GetCommentsInBatchResp, err error) {
+	resp = new(GetCommentsInBatchResp)
+	if len(commentIds) > maxCommentBatchSize {
+		return resp, consts.ErrIllegalOperation
+	}
+	var comments map[string]*commentMapper.Comment
+	if comments, err = s.CommentMongoMapper.FindManyByIds(ctx, commentIds); err != nil {
+		log.CtxError(ctx, "批量获取评论 失败[%v]\n", err)
+		return resp, err
+	}
+
+	resp.Comments = lo.Map(commentIds, func(id string, _ int) *BatchComment {
+		data, ok := comments[id]
+		switch {
+		case !ok:
+			return &BatchComment{CommentId: id, NotFound: true}
+		case data.State == consts.DeletedState:
+			return &BatchComment{CommentId: id, Deleted: true}
+		default:
+			return &BatchComment{CommentId: id, Comment: toCommentDetail(data)}
+		}
+	})
+	return resp, nil
+}
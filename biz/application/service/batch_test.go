@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"testing"
+)
+
+// batchMapper 只实现 FindManyByIds，返回预先准备的评论
+type batchMapper struct {
+	commentMapper.IMongoMapper
+	comments map[string]*commentMapper.Comment
+}
+
+func (m *batchMapper) FindManyByIds(_ context.Context, ids []string) (map[string]*commentMapper.Comment, error) {
+	return lo.PickByKeys(m.comments, ids), nil
+}
+
+func TestGetCommentsInBatch(t *testing.T) {
+	newComment := func(state int64) *commentMapper.Comment {
+		return &commentMapper.Comment{ID: primitive.NewObjectID(), State: state, Count: lo.ToPtr[int64](0)}
+	}
+	normal, folded, deleted := newComment(consts.NormalState), newComment(consts.FoldedState), newComment(consts.DeletedState)
+	missing := primitive.NewObjectID().Hex()
+	s := &CommentService{CommentMongoMapper: &batchMapper{comments: lo.KeyBy([]*commentMapper.Comment{normal, folded, deleted}, func(comment *commentMapper.Comment) string {
+		return comment.ID.Hex()
+	})}}
+
+	type result struct {
+		id       string
+		found    bool
+		notFound bool
+		deleted  bool
+	}
+	tests := []struct {
+		name string
+		ids  []string
+		want []result
+	}{
+		{name: "空请求"},
+		{name: "按请求顺序返回", ids: []string{folded.ID.Hex(), normal.ID.Hex()}, want: []result{
+			{id: folded.ID.Hex(), found: true},
+			{id: normal.ID.Hex(), found: true},
+		}},
+		{name: "不存在与已删除只做标记", ids: []string{missing, deleted.ID.Hex(), normal.ID.Hex()}, want: []result{
+			{id: missing, notFound: true},
+			{id: deleted.ID.Hex(), deleted: true},
+			{id: normal.ID.Hex(), found: true},
+		}},
+		{name: "重复的 id 返回相同的结果", ids: []string{normal.ID.Hex(), missing, normal.ID.Hex()}, want: []result{
+			{id: normal.ID.Hex(), found: true},
+			{id: missing, notFound: true},
+			{id: normal.ID.Hex(), found: true},
+		}},
+		{name: "无效 id", ids: []string{"invalid"}, want: []result{{id: "invalid", notFound: true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.GetCommentsInBatch(context.Background(), tt.ids)
+			if err != nil {
+				t.Fatalf("GetCommentsInBatch() error = %v", err)
+			}
+			got := lo.Map(resp.Comments, func(comment *BatchComment, _ int) result {
+				found := comment.Comment != nil && comment.Comment.CommentId == comment.CommentId
+				return result{id: comment.CommentId, found: found, notFound: comment.NotFound, deleted: comment.Deleted}
+			})
+			if len(got) != len(tt.want) || len(got) > 0 && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetCommentsInBatch() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCommentsInBatchLimit(t *testing.T) {
+	s := &CommentService{CommentMongoMapper: &batchMapper{}}
+	ids := make([]string, maxCommentBatchSize+1)
+	if _, err := s.GetCommentsInBatch(context.Background(), ids); err != consts.ErrIllegalOperation {
+		t.Errorf("GetCommentsInBatch() error = %v, want %v", err, consts.ErrIllegalOperation)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	changeMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/change"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	subjectMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/subject"
+	"github.com/samber/lo"
+	"time"
+)
+
+const (
+	defaultChangeLimit = 100
+	maxChangeLimit     = 500
+)
+
+// CommentChanges 评论区自水位之后的增量变更，同一评论的多次变更只返回最新状态
+type CommentChanges struct {
+	// Changed 新增、编辑、恢复或状态变更后仍对外可见的评论，按最后一次变更的顺序排列
+	Changed []*CommentDetail `json:"changed"`
+	// Removed 已删除或变为不可见的评论 id，客户端应从列表中移除
+	Removed []string `json:"removed"`
+	// RootCounts 受影响的一级评论当前的回复数
+	RootCounts map[string]int64 `json:"rootCounts"`
+	RootCount  int64            `json:"rootCount"`
+	AllCount   int64            `json:"allCount"`
+	// Seq 下一次拉取使用的水位
+	Seq int64 `json:"seq"`
+	// HasMore 为 true 时还有未返回的变更，应立即以 Seq 继续拉取
+	HasMore bool `json:"hasMore"`
+}
+
+// GetCommentChanges 返回评论区中序号大于 seq 的评论变更，seq 为 0 且 since 大于 0 时改为返回 since（毫秒）之后的变更
+func (s *CommentService) GetCommentChanges(ctx context.Context, subjectId string, seq, since, limit int64) (resp *CommentChanges, err error) {
+	resp = &CommentChanges{RootCounts: make(map[string]int64), Seq: seq}
+	if limit <= 0 {
+		limit = defaultChangeLimit
+	}
+	limit = lo.Min([]int64{limit, maxChangeLimit})
+
+	// 先读取评论区再读取变更：序号不大于此时评论区序号的变更都已提交，没有更多变更时可以直接推进水位
+	var subject *subjectMapper.Subject
+	if subject, err = s.SubjectMongoMapper.FindOne(ctx, subjectId); err != nil {
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return resp, err
+	}
+	resp.RootCount, resp.AllCount = lo.FromPtr(subject.RootCount), lo.FromPtr(subject.AllCount)
+
+	var (
+		after   time.Time
+		changes []*changeMapper.Change
+	)
+	if seq <= 0 && since > 0 {
+		after = time.UnixMilli(since)
+	}
+	if changes, err = s.ChangeMongoMapper.FindSince(ctx, subjectId, seq, after, limit); err != nil {
+		log.CtxError(ctx, "获取评论变更 失败[%v]\n", err)
+		return resp, err
+	}
+	resp.Seq = lo.Max([]int64{seq, subject.Seq})
+	if len(changes) == 0 {
+		return resp, nil
+	}
+	// 变更数达到上限时评论区序号之前可能还有未返回的变更，只能推进到最后一条变更
+	last := changes[len(changes)-1].Seq
+	if resp.HasMore = int64(len(changes)) == limit; resp.HasMore {
+		resp.Seq = last
+	} else {
+		resp.Seq = lo.Max([]int64{resp.Seq, last})
+	}
+
+	// 同一评论只保留最后一次变更，按该次变更的顺序返回
+	latest := lo.Reverse(lo.UniqBy(lo.Reverse(changes), func(change *changeMapper.Change) string {
+		return change.CommentId
+	}))
+	var comments []*commentMapper.Comment
+	if comments, err = s.CommentMongoMapper.FindAll(ctx, &commentMapper.FilterOptions{
+		OnlyCommentIds: lo.Map(latest, func(change *changeMapper.Change, _ int) string { return change.CommentId }),
+	}); err != nil {
+		log.CtxError(ctx, "获取评论 失败[%v]\n", err)
+		return resp, err
+	}
+	byId := lo.KeyBy(comments, func(comment *commentMapper.Comment) string {
+		return comment.ID.Hex()
+	})
+	for _, change := range latest {
+		if data, ok := byId[change.CommentId]; ok && !lo.Contains(consts.InvisibleStates, data.State) {
+			resp.Changed = append(resp.Changed, toCommentDetail(data))
+		} else {
+			resp.Removed = append(resp.Removed, change.CommentId)
+		}
+	}
+
+	// 回复的变更会影响一级评论的回复数，一并返回这些一级评论的最新计数
+	rootIds := lo.Uniq(lo.FilterMap(latest, func(change *changeMapper.Change, _ int) (string, bool) {
+		return change.RootId, change.RootId != subjectId
+	}))
+	if len(rootIds) == 0 {
+		return resp, nil
+	}
+	var roots []*commentMapper.Comment
+	if roots, err = s.CommentMongoMapper.FindAll(ctx, &commentMapper.FilterOptions{OnlyCommentIds: rootIds}); err != nil {
+		log.CtxError(ctx, "获取一级评论 失败[%v]\n", err)
+		return resp, err
+	}
+	for _, root := range roots {
+		resp.RootCounts[root.ID.Hex()] = lo.FromPtr(root.Count)
+	}
+	return resp, nil
+}
+
+// recordChanges 在事务中为评论区分配连续的变更序号，并为每条评论写入一条变更记录
+func recordChanges(ctx context.Context, subjects subjectMapper.IMongoMapper, mapper changeMapper.IMongoMapper, subjectId string, op int64, comments ...*commentMapper.Comment) ([]*changeMapper.Change, error) {
+	if len(comments) == 0 {
+		return nil, nil
+	}
+	last, err := subjects.IncrSeq(ctx, subjectId, int64(len(comments)))
+	if err != nil {
+		return nil, err
+	}
+	first := last - int64(len(comments)) + 1
+	changes := lo.Map(comments, func(comment *commentMapper.Comment, i int) *changeMapper.Change {
+		return &changeMapper.Change{
+			SubjectId: subjectId,
+			Seq:       first + int64(i),
+			CommentId: comment.ID.Hex(),
+			RootId:    comment.RootId,
+			Op:        op,
+		}
+	})
+	return changes, mapper.InsertMany(ctx, changes)
+}
@@ -2,44 +2,99 @@ package service
 
 import (
 	"context"
-	"github.com/CloudStriver/cloudmind-mq/app/util/message"
-	"github.com/CloudStriver/go-pkg/utils/pagination"
-	"github.com/CloudStriver/go-pkg/utils/pconvertor"
+	"errors"
 	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
 	"github.com/CloudStriver/platform/biz/infrastructure/consts"
 	"github.com/CloudStriver/platform/biz/infrastructure/convertor"
+	"github.com/CloudStriver/platform/biz/infrastructure/event"
+	"github.com/CloudStriver/platform/biz/infrastructure/idempotent"
 	"github.com/CloudStriver/platform/biz/infrastructure/kq"
+	"github.com/CloudStriver/platform/biz/infrastructure/limiter"
+	changeMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/change"
 	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	moderationMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/moderation"
+	outboxMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/outbox"
+	reactionMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/reaction"
+	recycleMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/recycle"
+	revisionMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/revision"
 	subjectMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/subject"
+	"github.com/CloudStriver/platform/biz/infrastructure/sensitive"
 	"github.com/CloudStriver/platform/biz/infrastructure/sort"
+	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/basic"
 	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform"
-	"github.com/bytedance/sonic"
+	"github.com/bytedance/gopkg/cloud/metainfo"
 	"github.com/google/wire"
 	"github.com/samber/lo"
+	"github.com/zeromicro/go-zero/core/metric"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"math"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ICommentService interface {
-	UpdateCount(ctx context.Context, rootId string, count int64)
 	GetComment(ctx context.Context, req *platform.GetCommentReq) (resp *platform.GetCommentResp, err error)
-	GetCommentList(ctx context.Context, req *platform.GetCommentListReq) (resp *platform.GetCommentListResp, err error)
-	GetCommentBlocks(ctx context.Context, req *platform.GetCommentBlocksReq) (resp *platform.GetCommentBlocksResp, err error)
+	GetCommentList(ctx context.Context, req *platform.GetCommentListReq, sortMode int64) (resp *platform.GetCommentListResp, err error)
+	GetCommentListByFilter(ctx context.Context, filter *commentMapper.FilterOptions, pagination *basic.PaginationOptions, sortMode int64) (resp *CommentDetailList, err error)
+	GetCommentBlocks(ctx context.Context, req *platform.GetCommentBlocksReq, sortMode int64, replyOpts *ReplyPreviewOptions) (resp *platform.GetCommentBlocksResp, err error)
+	GetCommentListAround(ctx context.Context, req *platform.GetCommentListReq, sortMode int64, commentId string) (resp *platform.GetCommentListResp, err error)
+	GetCommentBlocksAround(ctx context.Context, req *platform.GetCommentBlocksReq, sortMode int64, replyOpts *ReplyPreviewOptions, commentId string) (resp *platform.GetCommentBlocksResp, err error)
 	CreateComment(ctx context.Context, req *platform.CreateCommentReq) (resp *platform.CreateCommentResp, err error)
 	UpdateComment(ctx context.Context, req *platform.UpdateCommentReq) (resp *platform.UpdateCommentResp, err error)
-	DeleteComment(ctx context.Context, commentId string, commentType int64, level bool) (resp *platform.DeleteCommentResp, err error)
+	DeleteComment(ctx context.Context, req *platform.DeleteCommentReq) (resp *platform.DeleteCommentResp, err error)
 	DeleteCommentByIds(ctx context.Context, req *platform.DeleteCommentByIdsReq) (resp *platform.DeleteCommentByIdsResp, err error)
-	SetCommentAttrs(ctx context.Context, req *platform.SetCommentAttrsReq, res *platform.GetCommentSubjectResp) (resp *platform.SetCommentAttrsResp, err error)
+	SetCommentAttrs(ctx context.Context, req *platform.SetCommentAttrsReq) (resp *platform.SetCommentAttrsResp, err error)
+	PinComment(ctx context.Context, subjectId, commentId string, expireAt int64) (err error)
+	UnpinComment(ctx context.Context, subjectId, commentId string) (err error)
+	ReorderPins(ctx context.Context, subjectId string, commentIds []string) (err error)
+	EditComment(ctx context.Context, commentId, userId, content string) (resp *CommentDetail, err error)
+	GetCommentRevisions(ctx context.Context, commentId string, pagination *basic.PaginationOptions) (resp *GetCommentRevisionsResp, err error)
+	ModerateComment(ctx context.Context, commentId, operatorId string, state int64, reason string) (err error)
+	GetModerationQueue(ctx context.Context, subjectId *string, state int64, pagination *basic.PaginationOptions) (resp *CommentDetailList, err error)
+	GetModerationLogs(ctx context.Context, commentId string, pagination *basic.PaginationOptions) (resp *GetModerationLogsResp, err error)
+	GetCommentTree(ctx context.Context, rootId string, maxDepth, maxBreadth int64) (root *CommentNode, err error)
+	GetCommentChain(ctx context.Context, commentId string, maxDepth int64) (chain []*CommentDetail, err error)
+	React(ctx context.Context, commentId, userId string, kind int64) (err error)
+	Unreact(ctx context.Context, commentId, userId string) (err error)
+	GetCommentReactions(ctx context.Context, userId string, commentIds []string) (resp []*CommentReactions, err error)
+	SearchComments(ctx context.Context, keyword string, fopts *commentMapper.EsFilterOptions, pagination *basic.PaginationOptions) (resp *SearchCommentsResp, err error)
+	GetCommentChanges(ctx context.Context, subjectId string, seq, since, limit int64) (resp *CommentChanges, err error)
+	GetCommentsInBatch(ctx context.Context, commentIds []string) (resp *GetCommentsInBatchResp, err error)
 }
 
 type CommentService struct {
-	CommentMongoMapper      commentMapper.IMongoMapper
-	SubjectMongoMapper      subjectMapper.IMongoMapper
-	DeleteCommentRelationKq *kq.DeleteCommentRelationKq
+	Config                *config.Config
+	CommentMongoMapper    commentMapper.IMongoMapper
+	CommentEsMapper       commentMapper.IEsMapper
+	SubjectMongoMapper    subjectMapper.IMongoMapper
+	SensitiveFilter       *sensitive.Filter
+	Limiter               *limiter.Limiter
+	Idempotent            *idempotent.Store
+	RevisionMongoMapper   revisionMapper.IMongoMapper
+	RecycleMongoMapper    recycleMapper.IMongoMapper
+	ModerationMongoMapper moderationMapper.IMongoMapper
+	ReactionMongoMapper   reactionMapper.IMongoMapper
+	OutboxMongoMapper     outboxMapper.IMongoMapper
+	ChangeMongoMapper     changeMapper.IMongoMapper
+	CommentMentionKq      *kq.CommentMentionKq
+	Bus                   *event.Bus
 }
 
+const (
+	// defaultReplyPreviewSize 评论块中默认附带的回复条数
+	defaultReplyPreviewSize = 10
+	// maxReplyPreviewSize 评论块中最多附带的回复条数
+	maxReplyPreviewSize   = 50
+	prefixCommentLimitKey = "limit:comment:"
+	// ReplyPreviewSizeMetaKey 客户端通过 kitex metainfo 传递回复预览条数时使用的 key
+	ReplyPreviewSizeMetaKey = "REPLY_PREVIEW_SIZE"
+	// UserIdMetaKey 经过鉴权的调用方用户 id，由上游网关在鉴权后通过 kitex metainfo 传递
+	UserIdMetaKey = "USER_ID"
+)
+
 var CommentSet = wire.NewSet(
 	wire.Struct(new(CommentService), "*"),
 	wire.Bind(new(ICommentService), new(*CommentService)),
@@ -47,9 +102,11 @@ var CommentSet = wire.NewSet(
 
 func (s *CommentService) DeleteCommentByIds(ctx context.Context, req *platform.DeleteCommentByIdsReq) (resp *platform.DeleteCommentByIdsResp, err error) {
 	resp = new(platform.DeleteCommentByIdsResp)
-	if _, err = s.CommentMongoMapper.DeleteMany(ctx, req.CommentIds); err != nil {
-		log.CtxError(ctx, "删除评论 失败[%v]\n", err)
-		return resp, err
+	for _, id := range req.CommentIds {
+		// 一级评论会连带删除其回复，之后遇到的回复 id 已不存在
+		if _, err = s.DeleteComment(ctx, &platform.DeleteCommentReq{CommentId: id}); err != nil && !errors.Is(err, consts.ErrNotFound) {
+			return resp, err
+		}
 	}
 	return resp, nil
 }
@@ -80,75 +137,126 @@ func (s *CommentService) GetComment(ctx context.Context, req *platform.GetCommen
 	return resp, nil
 }
 
-func (s *CommentService) GetCommentList(ctx context.Context, req *platform.GetCommentListReq) (resp *platform.GetCommentListResp, err error) {
+func (s *CommentService) GetCommentList(ctx context.Context, req *platform.GetCommentListReq, sortMode int64) (resp *platform.GetCommentListResp, err error) {
 	resp = new(platform.GetCommentListResp)
+	var list *CommentDetailList
+	if list, err = s.GetCommentListByFilter(ctx, convertor.CommentFilterOptionsToFilterOptions(req.FilterOptions), req.Pagination, sortMode); err != nil {
+		return resp, err
+	}
+	resp.Comments = lo.Map(list.Comments, func(detail *CommentDetail, _ int) *platform.Comment {
+		return detail.Comment
+	})
+	resp.Total, resp.Token = list.Total, list.Token
+	return resp, nil
+}
+
+// GetCommentListByFilter 与 GetCommentList 相同，但支持评论区、标签、类型、创建时间与是否有回复等 CommentFilterOptions 无法表达的条件
+func (s *CommentService) GetCommentListByFilter(ctx context.Context, filter *commentMapper.FilterOptions, pagination *basic.PaginationOptions, sortMode int64) (resp *CommentDetailList, err error) {
+	resp = new(CommentDetailList)
 	var (
 		total    int64
 		comments []*commentMapper.Comment
 	)
 
-	p := convertor.ParsePagination(req.Pagination)
-	filter := convertor.CommentFilterOptionsToFilterOptions(req.FilterOptions)
-	if comments, total, err = s.CommentMongoMapper.FindManyAndCount(ctx, filter, p, sort.TimeCursorType); err != nil {
+	p := convertor.ParsePagination(pagination)
+	filter.ExcludeStates = []int64{consts.DeletedState}
+	// 一级评论列表中置顶评论不参与排序，只在第一页按置顶顺序排在最前
+	var pinned []*commentMapper.Comment
+	if filter.OnlySubjectId != nil && lo.FromPtr(filter.OnlyRootId) == *filter.OnlySubjectId {
+		if pinned, filter.ExcludeCommentIds, err = s.findPinned(ctx, *filter.OnlySubjectId, filter); err != nil {
+			return resp, err
+		}
+	}
+	if comments, total, err = s.CommentMongoMapper.FindManyAndCount(ctx, filter, p, sort.CommentCursorType(sortMode)); err != nil {
 		log.CtxError(ctx, "获取评论列表 失败[%v]\n", err)
 		return resp, err
 	}
+	if pagination == nil || pagination.LastToken == nil {
+		comments = append(pinned, comments...)
+	}
+	total += int64(len(pinned))
 	if p.LastToken != nil {
 		resp.Token = *p.LastToken
 	}
-	resp.Comments = lo.Map(comments, func(comment *commentMapper.Comment, _ int) *platform.Comment {
-		return convertor.CommentMapperToComment(comment)
-	})
+	resp.Comments = toCommentDetails(comments)
 	resp.Total = total
 	return resp, nil
 }
 
-func (s *CommentService) GetCommentBlocks(ctx context.Context, req *platform.GetCommentBlocksReq) (resp *platform.GetCommentBlocksResp, err error) {
+// ReplyPreviewOptions 评论块中每条一级评论附带的回复条数与回复排序
+type ReplyPreviewOptions struct {
+	Size     int64
+	SortMode int64
+}
+
+// ReplyPreviewOptionsFromContext 读取请求携带的回复预览条数与排序模式，未携带时使用默认值
+func ReplyPreviewOptionsFromContext(ctx context.Context) *ReplyPreviewOptions {
+	value, ok := metainfo.GetValue(ctx, ReplyPreviewSizeMetaKey)
+	if !ok {
+		value, _ = metainfo.GetPersistentValue(ctx, ReplyPreviewSizeMetaKey)
+	}
+	size, _ := strconv.ParseInt(value, 10, 64)
+	return &ReplyPreviewOptions{Size: size, SortMode: sort.ReplySortModeFromContext(ctx)}
+}
+
+// UserIdFromContext 读取请求携带的调用方用户 id，未携带时返回空字符串
+func UserIdFromContext(ctx context.Context) string {
+	if userId, ok := metainfo.GetValue(ctx, UserIdMetaKey); ok {
+		return userId
+	}
+	userId, _ := metainfo.GetPersistentValue(ctx, UserIdMetaKey)
+	return userId
+}
+
+// withDefaults 补齐未指定的回复预览选项，条数不超过 maxReplyPreviewSize
+func (o *ReplyPreviewOptions) withDefaults() *ReplyPreviewOptions {
+	opts := ReplyPreviewOptions{SortMode: sort.NewestSortMode}
+	if o != nil {
+		opts = *o
+	}
+	if opts.Size <= 0 {
+		opts.Size = defaultReplyPreviewSize
+	}
+	opts.Size = lo.Min([]int64{opts.Size, maxReplyPreviewSize})
+	return &opts
+}
+
+func (s *CommentService) GetCommentBlocks(ctx context.Context, req *platform.GetCommentBlocksReq, sortMode int64, replyOpts *ReplyPreviewOptions) (resp *platform.GetCommentBlocksResp, err error) {
 	resp = new(platform.GetCommentBlocksResp)
+	replyOpts = replyOpts.withDefaults()
 
 	var (
-		total     int64
-		comments  []*commentMapper.Comment
-		replyList []*commentMapper.Comment
-		filter    *commentMapper.FilterOptions
+		total    int64
+		comments []*commentMapper.Comment
+		filter   *commentMapper.FilterOptions
 	)
 
 	p := convertor.ParsePagination(req.Pagination)
-	filter = &commentMapper.FilterOptions{OnlyRootId: lo.ToPtr(req.RootId)}
+	filter = &commentMapper.FilterOptions{OnlyRootId: lo.ToPtr(req.RootId), ExcludeStates: consts.InvisibleStates}
 	if req.RootId == req.SubjectId {
-		if comments, total, err = s.CommentMongoMapper.FindManyAndCount(ctx, filter, p, sort.TimeCursorType); err != nil {
+		// 已删除的一级评论仅在还有回复时作为占位返回
+		filter.ExcludeEmptyTombstones = true
+		var pinned []*commentMapper.Comment
+		if pinned, filter.ExcludeCommentIds, err = s.findPinned(ctx, req.SubjectId, filter); err != nil {
+			return resp, err
+		}
+		if comments, total, err = s.CommentMongoMapper.FindManyAndCount(ctx, filter, p, sort.CommentCursorType(sortMode)); err != nil {
 			log.CtxError(ctx, "获取评论列表 失败[%v]\n", err)
 			return resp, err
 		}
+		if req.Pagination == nil || req.Pagination.LastToken == nil {
+			comments = append(pinned, comments...)
+		}
+		total += int64(len(pinned))
 		if p.LastToken != nil {
 			resp.Token = *p.LastToken
 		}
 		resp.Total = total
-		resp.CommentBlocks = lo.Map(comments, func(comment *commentMapper.Comment, _ int) *platform.CommentBlock {
-			return &platform.CommentBlock{
-				RootComment: convertor.CommentMapperToComment(comment),
-				ReplyList:   &platform.ReplyList{},
-			}
-		})
-
-		for i, comment := range comments {
-			p = &pagination.PaginationOptions{}
-			filter = &commentMapper.FilterOptions{OnlyRootId: lo.ToPtr(comment.ID.Hex())}
-			if replyList, total, err = s.CommentMongoMapper.FindManyAndCount(ctx, filter, p, sort.TimeCursorType); err != nil {
-				log.CtxError(ctx, "获取评论列表 失败[%v]\n", err)
-				return resp, err
-			}
-
-			if p.LastToken != nil {
-				resp.CommentBlocks[i].ReplyList.Token = *p.LastToken
-			}
-			resp.CommentBlocks[i].ReplyList.Total = total
-			resp.CommentBlocks[i].ReplyList.Comments = lo.Map(replyList, func(comment *commentMapper.Comment, _ int) *platform.Comment {
-				return convertor.CommentMapperToComment(comment)
-			})
+		if resp.CommentBlocks, err = s.buildCommentBlocks(ctx, comments, replyOpts); err != nil {
+			return resp, err
 		}
 	} else {
-		if comments, total, err = s.CommentMongoMapper.FindManyAndCount(ctx, filter, p, sort.TimeCursorType); err != nil {
+		if comments, total, err = s.CommentMongoMapper.FindManyAndCount(ctx, filter, p, sort.CommentCursorType(replyOpts.SortMode)); err != nil {
 			log.CtxError(ctx, "获取评论列表 失败[%v]\n", err)
 			return resp, err
 		}
@@ -167,12 +275,72 @@ func (s *CommentService) GetCommentBlocks(ctx context.Context, req *platform.Get
 	return resp, nil
 }
 
+// buildCommentBlocks 为一页一级评论组装评论块，一次聚合取回所有一级评论的回复预览与回复数
+func (s *CommentService) buildCommentBlocks(ctx context.Context, comments []*commentMapper.Comment, replyOpts *ReplyPreviewOptions) ([]*platform.CommentBlock, error) {
+	blocks := lo.Map(comments, func(comment *commentMapper.Comment, _ int) *platform.CommentBlock {
+		return &platform.CommentBlock{
+			RootComment: convertor.CommentMapperToComment(comment),
+			ReplyList:   &platform.ReplyList{},
+		}
+	})
+	rootIds := lo.Map(comments, func(comment *commentMapper.Comment, _ int) string {
+		return comment.ID.Hex()
+	})
+	replyFilter := &commentMapper.FilterOptions{ExcludeStates: consts.InvisibleStates}
+	previews, err := s.CommentMongoMapper.FindReplyPreviews(ctx, rootIds, replyFilter, replyOpts.Size, sort.CommentCursorType(replyOpts.SortMode))
+	if err != nil {
+		log.CtxError(ctx, "获取回复预览 失败[%v]\n", err)
+		return nil, err
+	}
+	for i, rootId := range rootIds {
+		preview, ok := previews[rootId]
+		if !ok {
+			continue
+		}
+		replyList := blocks[i].ReplyList
+		replyList.Total = preview.Total
+		if preview.Token != nil {
+			replyList.Token = *preview.Token
+		}
+		replyList.Comments = lo.Map(preview.Replies, func(comment *commentMapper.Comment, _ int) *platform.Comment {
+			return convertor.CommentMapperToComment(comment)
+		})
+	}
+	return blocks, nil
+}
+
+// CreateComment 创建评论，携带幂等键的重试请求直接返回首次创建的评论 id，不会重复写入与计数
 func (s *CommentService) CreateComment(ctx context.Context, req *platform.CreateCommentReq) (resp *platform.CreateCommentResp, err error) {
 	resp = new(platform.CreateCommentResp)
-	if resp.CommentId, err = s.CommentMongoMapper.Insert(ctx, &commentMapper.Comment{
+	resp.CommentId, err = s.Idempotent.Do(ctx, "comment:"+req.UserId, idempotent.KeyFromContext(ctx), func() (string, error) {
+		res, err := s.createComment(ctx, req)
+		return res.CommentId, err
+	})
+	return resp, err
+}
+
+func (s *CommentService) createComment(ctx context.Context, req *platform.CreateCommentReq) (resp *platform.CreateCommentResp, err error) {
+	resp = new(platform.CreateCommentResp)
+	var subject *subjectMapper.Subject
+	if subject, err = s.SubjectMongoMapper.FindOne(ctx, req.SubjectId); err != nil {
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return resp, err
+	}
+	if err = checkCommentable(subject, req); err != nil {
+		return resp, err
+	}
+	var atUserId string
+	if atUserId, err = s.checkHierarchy(ctx, req); err != nil {
+		return resp, err
+	}
+	if err = s.checkRateLimit(ctx, req.UserId, subject); err != nil {
+		return resp, err
+	}
+
+	data := &commentMapper.Comment{
 		ID:        primitive.NilObjectID,
 		UserId:    req.UserId,
-		AtUserId:  req.AtUserId,
+		AtUserId:  atUserId,
 		SubjectId: req.SubjectId,
 		RootId:    req.RootId,
 		FatherId:  req.FatherId,
@@ -183,28 +351,215 @@ func (s *CommentService) CreateComment(ctx context.Context, req *platform.Create
 		State:     int64(platform.State_Normal),
 		Attrs:     int64(platform.Attrs_None),
 		Type:      req.Type,
+		Mentions:  parseMentions(req.Content),
+	}
+	// 开启先审后发的评论区，新评论需要审核通过后才可见并计入计数
+	if lo.FromPtr(subject.PreModeration) {
+		data.State = consts.PendingState
+	}
+	// 命中的敏感词随评论保存并写入审核记录
+	if data.Content, data.State, data.Sensitive, err = s.checkSensitive(ctx, subject, req.UserId, req.Content, data.State); err != nil {
+		return resp, err
+	}
+	delta := countDelta(data.State, consts.Increment)
+
+	var changes []*changeMapper.Change
+	if err = withTransaction(ctx, s.CommentMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+		var err1 error
+		if resp.CommentId, err1 = s.CommentMongoMapper.Insert(sessionContext, data); err1 != nil {
+			log.CtxError(sessionContext, "创建评论 产生错误[%v]\n", err1)
+			return err1
+		}
+		if len(data.Sensitive) > 0 {
+			if _, err1 = s.ModerationMongoMapper.Insert(sessionContext, &moderationMapper.Moderation{
+				CommentId: resp.CommentId,
+				SubjectId: data.SubjectId,
+				ToState:   data.State,
+				Reason:    "命中敏感词: " + strings.Join(data.Sensitive, ","),
+			}); err1 != nil {
+				log.CtxError(sessionContext, "记录审核操作 产生错误[%v]\n", err1)
+				return err1
+			}
+		}
+		if err1 = s.incrCount(sessionContext, data.SubjectId, data.RootId, delta, delta); err1 != nil {
+			log.CtxError(sessionContext, "更新评论数 产生错误[%v]\n", err1)
+			return err1
+		}
+		if changes, err1 = recordChanges(sessionContext, s.SubjectMongoMapper, s.ChangeMongoMapper, data.SubjectId, changeMapper.CreateOp, data); err1 != nil {
+			log.CtxError(sessionContext, "记录评论变更 产生错误[%v]\n", err1)
+			return err1
+		}
+		return nil
 	}); err != nil {
 		log.CtxError(ctx, "创建评论 失败[%v]\n", err)
 		return resp, err
 	}
+	publishChanges(s.Bus, changes, data)
+	s.indexComment(ctx, data)
+	s.pushMentions(ctx, data, nil)
 	return resp, nil
 }
 
-func (s *CommentService) UpdateCount(ctx context.Context, rootId string, count int64) {
-	s.CommentMongoMapper.UpdateCount(ctx, rootId, count)
+// checkSensitive 按评论区类型的策略处理内容中的敏感词，发表与编辑评论都需要经过该检查
+// 返回处理后的内容与状态以及命中的敏感词：mask 策略替换为掩码，moderate 策略转为待审核，其余策略拒绝
+func (s *CommentService) checkSensitive(ctx context.Context, subject *subjectMapper.Subject, userId, content string, state int64) (string, int64, []string, error) {
+	masked, words, found := s.SensitiveFilter.Check(content)
+	if !found {
+		return content, state, nil, nil
+	}
+	switch s.Config.GetSubjectTypeConf(subject.Type).SensitivePolicy {
+	case sensitive.PolicyMask:
+		return masked, state, words, nil
+	case sensitive.PolicyModerate:
+		return content, consts.PendingState, words, nil
+	default:
+		log.CtxInfo(ctx, "评论命中敏感词被拒绝: 用户[%s] 评论区[%s] 敏感词%v\n", userId, subject.ID.Hex(), words)
+		return content, state, words, consts.ErrSensitiveContent
+	}
+}
+
+// checkCommentable 按评论区的状态与属性判断能否发表该评论
+func checkCommentable(subject *subjectMapper.Subject, req *platform.CreateCommentReq) error {
+	switch subject.State {
+	case consts.SubjectArchivedState:
+		return consts.ErrSubjectArchived
+	case consts.SubjectClosedState, consts.SubjectDeletingState:
+		return consts.ErrSubjectClosed
+	}
+	if req.RootId == req.SubjectId {
+		return nil
+	}
+	if consts.HasSubjectAttr(subject.Attrs, consts.SubjectRepliesDisabledAttr) {
+		return consts.ErrRepliesDisabled
+	}
+	if consts.HasSubjectAttr(subject.Attrs, consts.SubjectOwnerReplyOnlyAttr) && req.UserId != subject.UserId {
+		return consts.ErrOwnerReplyOnly
+	}
+	return nil
+}
+
+// checkHierarchy 校验回复的一级评论与父评论都在该评论区中、父评论属于该一级评论且均未被删除或隐藏
+// 返回被回复的用户，请求未指定时取父评论的作者
+func (s *CommentService) checkHierarchy(ctx context.Context, req *platform.CreateCommentReq) (atUserId string, err error) {
+	if req.RootId == req.SubjectId {
+		if req.FatherId != req.SubjectId {
+			return "", consts.ErrInvalidHierarchy
+		}
+		return req.AtUserId, nil
+	}
+
+	var root, father *commentMapper.Comment
+	if root, err = s.CommentMongoMapper.FindOne(ctx, req.RootId); err != nil {
+		if errors.Is(err, consts.ErrNotFound) || errors.Is(err, consts.ErrInvalidId) {
+			return "", consts.ErrInvalidHierarchy
+		}
+		log.CtxError(ctx, "获取一级评论 失败[%v]\n", err)
+		return "", err
+	}
+	if root.SubjectId != req.SubjectId || root.RootId != root.SubjectId || !consts.IsCounted(root.State) {
+		return "", consts.ErrInvalidHierarchy
+	}
+	father = root
+	if req.FatherId != req.RootId {
+		if father, err = s.CommentMongoMapper.FindOne(ctx, req.FatherId); err != nil {
+			if errors.Is(err, consts.ErrNotFound) || errors.Is(err, consts.ErrInvalidId) {
+				return "", consts.ErrInvalidHierarchy
+			}
+			log.CtxError(ctx, "获取父评论 失败[%v]\n", err)
+			return "", err
+		}
+		if father.SubjectId != req.SubjectId || father.RootId != req.RootId || !consts.IsCounted(father.State) {
+			return "", consts.ErrInvalidHierarchy
+		}
+	}
+	if req.AtUserId != "" {
+		return req.AtUserId, nil
+	}
+	return father.UserId, nil
+}
+
+// checkWritable 归档的评论区只读，其中的评论不能再修改
+func (s *CommentService) checkWritable(ctx context.Context, subjectId string) (*subjectMapper.Subject, error) {
+	subject, err := s.SubjectMongoMapper.FindOne(ctx, subjectId)
+	if err != nil {
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return nil, err
+	}
+	if subject.State == consts.SubjectArchivedState {
+		return nil, consts.ErrSubjectArchived
+	}
+	return subject, nil
+}
+
+// metricRateLimitFailures 限流检查因 redis 异常失败的次数，mode 为 open（放行）或 closed（拒绝）
+var metricRateLimitFailures = metric.NewCounterVec(&metric.CounterVecOpts{
+	Namespace: "platform",
+	Subsystem: "comment_rate_limit",
+	Name:      "failures_total",
+	Help:      "comment rate limit checks failed by redis errors",
+	Labels:    []string{"mode"},
+})
+
+// checkRateLimit 按用户、用户在评论区内以及评论区整体三个维度限制发表评论的频率
+// 限流只用于防刷，redis 不可用时默认放行以免影响正常评论，开启 FailClosed 时拒绝
+func (s *CommentService) checkRateLimit(ctx context.Context, userId string, subject *subjectMapper.Subject) error {
+	conf := s.Config.GetRateLimitConf(subject.Type)
+	subjectId := subject.ID.Hex()
+	allowed, err := s.Limiter.Allow(ctx,
+		limiter.Rule{Key: prefixCommentLimitKey + "user:" + userId, Limit: conf.User.Limit, Window: conf.User.Window},
+		limiter.Rule{Key: prefixCommentLimitKey + "user_subject:" + userId + ":" + subjectId, Limit: conf.UserSubject.Limit, Window: conf.UserSubject.Window},
+		limiter.Rule{Key: prefixCommentLimitKey + "subject:" + subjectId, Limit: conf.Subject.Limit, Window: conf.Subject.Window},
+	)
+	if err != nil {
+		if conf.FailClosed {
+			metricRateLimitFailures.Inc("closed")
+			log.CtxError(ctx, "评论限流检查 失败，拒绝发表: 用户[%s] 评论区[%s] 错误[%v]\n", userId, subjectId, err)
+			return consts.ErrRateLimited
+		}
+		metricRateLimitFailures.Inc("open")
+		log.CtxError(ctx, "评论限流检查 失败，放行: 用户[%s] 评论区[%s] 错误[%v]\n", userId, subjectId, err)
+		return nil
+	}
+	if !allowed {
+		log.CtxInfo(ctx, "评论被限流: 用户[%s] 评论区[%s]\n", userId, subjectId)
+		return consts.ErrRateLimited
+	}
+	return nil
+}
+
+// incrCount 根据评论层级原子地增减评论区与根评论的计数
+// 一级评论：评论区 rootCount 增减 delta，allCount 增减 allDelta（含被级联删除的回复）
+// 二级评论 + 三级评论：根评论 count 与评论区 allCount 增减 delta
+func (s *CommentService) incrCount(ctx context.Context, subjectId, rootId string, delta, allDelta int64) error {
+	if rootId == subjectId {
+		return s.SubjectMongoMapper.IncrCount(ctx, subjectId, delta, allDelta)
+	}
+	if err := s.CommentMongoMapper.IncrCount(ctx, rootId, delta); err != nil {
+		return err
+	}
+	return s.SubjectMongoMapper.IncrCount(ctx, subjectId, consts.InitNumber, delta)
 }
 
 func (s *CommentService) UpdateComment(ctx context.Context, req *platform.UpdateCommentReq) (resp *platform.UpdateCommentResp, err error) {
 	resp = new(platform.UpdateCommentResp)
-	var oid primitive.ObjectID
-	if oid, err = primitive.ObjectIDFromHex(req.CommentId); err != nil {
+	var data *commentMapper.Comment
+	if data, err = s.CommentMongoMapper.FindOne(ctx, req.CommentId); err != nil {
+		log.CtxError(ctx, "获取评论详情 失败[%v]\n", err)
+		return resp, err
+	}
+	if _, err = s.checkWritable(ctx, data.SubjectId); err != nil {
 		return resp, err
 	}
+	// 状态变更需要经过审核状态机并同步计数，操作人取自请求携带的调用方用户 id
+	if req.State != 0 {
+		if err = s.ModerateComment(ctx, req.CommentId, UserIdFromContext(ctx), req.State, ""); err != nil {
+			return resp, err
+		}
+	}
 	if _, err = s.CommentMongoMapper.Update(ctx, &commentMapper.Comment{
-		ID:     oid,
+		ID:     data.ID,
 		Meta:   req.Meta,
 		Labels: req.LabelIds,
-		State:  req.State,
 	}); err != nil {
 		log.CtxError(ctx, "更新评论 失败[%v]\n", err)
 		return resp, err
@@ -212,196 +567,187 @@ func (s *CommentService) UpdateComment(ctx context.Context, req *platform.Update
 	return resp, nil
 }
 
-func (s *CommentService) DeleteComment(ctx context.Context, commentId string, commentType int64, level bool) (resp *platform.DeleteCommentResp, err error) {
+func (s *CommentService) DeleteComment(ctx context.Context, req *platform.DeleteCommentReq) (resp *platform.DeleteCommentResp, err error) {
 	resp = new(platform.DeleteCommentResp)
 
-	if level {
-		var (
-			ids      []string
-			comments []*commentMapper.Comment
-		)
-		if err = s.CommentMongoMapper.GetConn().Find(ctx, &comments, bson.M{consts.RootId: commentId}); err != nil {
+	var (
+		data     *commentMapper.Comment
+		ids      []string
+		comments []*commentMapper.Comment
+	)
+	if data, err = s.CommentMongoMapper.FindOne(ctx, req.CommentId); err != nil {
+		log.CtxError(ctx, "获取评论详情 失败[%v]\n", err)
+		return resp, err
+	}
+	// 墓碑在其下的回复都被删除后才能彻底删除
+	if data.State == consts.DeletedState {
+		var replied bool
+		if replied, err = s.hasReplies(ctx, data); err != nil {
+			return resp, err
+		}
+		if replied {
+			return resp, consts.ErrNotFound
+		}
+	}
+
+	var subject *subjectMapper.Subject
+	if subject, err = s.SubjectMongoMapper.FindOne(ctx, data.SubjectId); err != nil {
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return resp, err
+	}
+	// 墓碑模式下被回复过的评论只清空内容，保留其在评论树中的位置
+	if data.State != consts.DeletedState && s.Config.GetSubjectTypeConf(subject.Type).Tombstone {
+		var replied bool
+		if replied, err = s.hasReplies(ctx, data); err != nil {
 			return resp, err
 		}
+		if replied {
+			return resp, s.tombstoneComment(ctx, data)
+		}
+	}
 
+	// 一级评论需要同时删除其下的所有回复
+	if data.RootId == data.SubjectId {
+		if err = s.CommentMongoMapper.GetConn().Find(ctx, &comments, bson.M{consts.RootId: req.CommentId}); err != nil {
+			return resp, err
+		}
 		ids = lo.Map(comments, func(comment *commentMapper.Comment, _ int) string {
 			return comment.ID.Hex()
 		})
+	}
 
-		tx := s.CommentMongoMapper.StartClient()
-		if err = tx.UseSession(ctx, func(sessionContext mongo.SessionContext) error {
-			var err1 error
-			if err1 = sessionContext.StartTransaction(); err1 != nil {
-				return err1
-			}
-			if _, err1 = s.CommentMongoMapper.Delete(sessionContext, commentId); err1 != nil {
-				if rbErr := sessionContext.AbortTransaction(sessionContext); rbErr != nil {
-					log.CtxError(sessionContext, "删除评论： 产生错误[%v]: 回滚异常[%v]\n", err1, rbErr)
-				}
-				return err1
-			}
+	var changes []*changeMapper.Change
+	if err = withTransaction(ctx, s.CommentMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+		var err1 error
+		if _, err1 = s.CommentMongoMapper.Delete(sessionContext, req.CommentId); err1 != nil {
+			log.CtxError(sessionContext, "删除评论： 产生错误[%v]\n", err1)
+			return err1
+		}
 
+		if len(ids) > 0 {
 			if _, err1 = s.CommentMongoMapper.DeleteMany(sessionContext, ids); err1 != nil {
-				if rbErr := sessionContext.AbortTransaction(sessionContext); rbErr != nil {
-					log.CtxError(sessionContext, "删除子评论 产生错误[%v]: 回滚异常[%v]\n", err1, rbErr)
-				}
-				return err1
-			}
-
-			if err1 = sessionContext.CommitTransaction(sessionContext); err1 != nil {
-				log.CtxError(sessionContext, "删除评论: 提交事务异常[%v]\n", err1)
+				log.CtxError(sessionContext, "删除子评论 产生错误[%v]\n", err1)
 				return err1
 			}
+		}
 
-			return nil
-		}); err != nil {
-			return resp, err
+		// 墓碑在回收站中已有删除前的快照，并入本次删除批次，不再用清空后的内容覆盖
+		isTombstone := func(comment *commentMapper.Comment, _ int) bool {
+			return comment.State == consts.DeletedState
+		}
+		tombstones, others := lo.Filter(append(comments, data), isTombstone), lo.Reject(append(comments, data), isTombstone)
+		if err1 = s.RecycleMongoMapper.MoveToBatch(sessionContext, lo.Map(tombstones, func(comment *commentMapper.Comment, _ int) string {
+			return comment.ID.Hex()
+		}), req.CommentId); err1 != nil {
+			log.CtxError(sessionContext, "移入回收站 产生错误[%v]\n", err1)
+			return err1
 		}
 
-		for _, v := range comments {
-			data, _ := sonic.Marshal(&message.DeleteCommentRelationsMessage{
-				FromType: v.Type,
-				FromId:   v.ID.Hex(),
-			})
-			if err2 := s.DeleteCommentRelationKq.Push(pconvertor.Bytes2String(data)); err2 != nil {
-				return resp, err2
-			}
+		// 移入回收站，历史版本与关联关系在清理时一并删除
+		if err1 = s.RecycleMongoMapper.InsertMany(sessionContext, lo.Map(others, func(comment *commentMapper.Comment, _ int) *recycleMapper.Recycle {
+			return &recycleMapper.Recycle{BatchId: req.CommentId, Comment: comment}
+		})); err1 != nil {
+			log.CtxError(sessionContext, "移入回收站 产生错误[%v]\n", err1)
+			return err1
 		}
 
-	} else {
-		if _, err = s.CommentMongoMapper.Delete(ctx, commentId); err != nil {
-			log.CtxError(ctx, "删除评论 失败[%v]\n", err)
-			return resp, err
+		delta, allDelta := deleteCountDelta(data, comments)
+		if err1 = s.incrCount(sessionContext, data.SubjectId, data.RootId, delta, allDelta); err1 != nil {
+			log.CtxError(sessionContext, "更新评论数 产生错误[%v]\n", err1)
+			return err1
 		}
-	}
 
-	data, _ := sonic.Marshal(&message.DeleteCommentRelationsMessage{
-		FromType: commentType,
-		FromId:   commentId,
-	})
-	if err2 := s.DeleteCommentRelationKq.Push(pconvertor.Bytes2String(data)); err2 != nil {
-		return resp, err2
+		if changes, err1 = recordChanges(sessionContext, s.SubjectMongoMapper, s.ChangeMongoMapper, data.SubjectId, changeMapper.DeleteOp, append(comments, data)...); err1 != nil {
+			log.CtxError(sessionContext, "记录评论变更 产生错误[%v]\n", err1)
+			return err1
+		}
+		return nil
+	}); err != nil {
+		log.CtxError(ctx, "删除评论 失败[%v]\n", err)
+		return resp, err
 	}
-
+	publishChanges(s.Bus, changes)
+	s.unindexComments(ctx, append(ids, req.CommentId))
 	return resp, nil
 }
 
-func (s *CommentService) SetCommentAttrs(ctx context.Context, req *platform.SetCommentAttrsReq, res *platform.GetCommentSubjectResp) (resp *platform.SetCommentAttrsResp, err error) {
-	resp = new(platform.SetCommentAttrsResp)
-	var (
-		subjectId primitive.ObjectID
-		commentId primitive.ObjectID
-	)
+// deleteCountDelta 删除评论及随其删除的回复时 incrCount 的参数，只扣减计入计数的评论，墓碑、待审核等状态的评论不在计数中
+func deleteCountDelta(data *commentMapper.Comment, replies []*commentMapper.Comment) (delta, allDelta int64) {
+	delta = countDelta(data.State, consts.Decrement)
+	return delta, delta - countedNumber(replies)
+}
 
-	if subjectId, err = primitive.ObjectIDFromHex(req.SubjectId); err != nil {
-		return resp, err
-	}
-	if commentId, err = primitive.ObjectIDFromHex(req.CommentId); err != nil {
-		return resp, err
+// countedNumber 统计计入计数的评论条数
+func countedNumber(comments []*commentMapper.Comment) int64 {
+	return int64(lo.CountBy(comments, func(comment *commentMapper.Comment) bool {
+		return consts.IsCounted(comment.State)
+	}))
+}
+
+// countDelta 处于 state 的评论增减时对计数的影响，不计入计数的状态不影响计数
+func countDelta(state, delta int64) int64 {
+	if consts.IsCounted(state) {
+		return delta
 	}
+	return consts.InitNumber
+}
 
-	switch req.Attrs {
-	case int64(platform.Attrs_Pinned):
-		req.SortTime = math.MaxInt64 - 1
-	case int64(platform.Attrs_PinnedAndHighlighted):
-		req.SortTime = math.MaxInt64 - 1
+// hasReplies 判断评论下是否还有未删除的回复
+func (s *CommentService) hasReplies(ctx context.Context, data *commentMapper.Comment) (bool, error) {
+	if data.RootId == data.SubjectId {
+		return lo.FromPtr(data.Count) > 0, nil
+	}
+	count, err := s.CommentMongoMapper.Count(ctx, &commentMapper.FilterOptions{
+		OnlyFatherId:  lo.ToPtr(data.ID.Hex()),
+		ExcludeStates: []int64{consts.DeletedState},
+	})
+	if err != nil {
+		log.CtxError(ctx, "统计回复数 失败[%v]\n", err)
+		return false, err
 	}
+	return count > 0, nil
+}
 
-	data := &commentMapper.Comment{ID: commentId, Attrs: req.Attrs, SortTime: req.SortTime}
-	tx := s.SubjectMongoMapper.StartClient()
-	if res.TopCommentId == "" {
-		err = tx.UseSession(ctx, func(sessionContext mongo.SessionContext) error {
-			if err = sessionContext.StartTransaction(); err != nil {
-				return err
-			}
-			if req.Attrs == int64(platform.Attrs_Pinned) || req.Attrs == int64(platform.Attrs_PinnedAndHighlighted) {
-				if _, err = s.SubjectMongoMapper.Update(sessionContext, &subjectMapper.Subject{ID: subjectId, TopCommentId: lo.ToPtr(req.CommentId)}); err != nil {
-					if rbErr := sessionContext.AbortTransaction(sessionContext); rbErr != nil {
-						log.CtxError(sessionContext, "设置评论属性失败[%v]: 回滚异常[%v]\n", err, rbErr)
-						return err
-					}
-				}
-			}
-			if _, err = s.CommentMongoMapper.Update(sessionContext, data); err != nil {
-				if rbErr := sessionContext.AbortTransaction(sessionContext); rbErr != nil {
-					log.CtxError(sessionContext, "设置评论属性失败[%v]: 回滚异常[%v]\n", err, rbErr)
-					return err
-				}
-			}
-			if err = sessionContext.CommitTransaction(sessionContext); err != nil {
-				log.CtxError(sessionContext, "设置评论属性: 提交事务异常[%v]\n", err)
-				return err
-			}
-			return nil
-		})
-	} else if res.TopCommentId == req.CommentId {
-		err = tx.UseSession(ctx, func(sessionContext mongo.SessionContext) error {
-			if err = sessionContext.StartTransaction(); err != nil {
-				return err
-			}
-			if req.Attrs == int64(platform.Attrs_None) || req.Attrs == int64(platform.Attrs_Highlighted) {
-				if _, err = s.SubjectMongoMapper.Update(sessionContext, &subjectMapper.Subject{ID: subjectId, TopCommentId: lo.ToPtr("")}); err != nil {
-					if rbErr := sessionContext.AbortTransaction(sessionContext); rbErr != nil {
-						log.CtxError(sessionContext, "设置评论属性失败[%v]: 回滚异常[%v]\n", err, rbErr)
-						return err
-					}
-				}
-			}
-			if _, err = s.CommentMongoMapper.Update(sessionContext, data); err != nil {
-				if rbErr := sessionContext.AbortTransaction(sessionContext); rbErr != nil {
-					log.CtxError(sessionContext, "设置评论属性失败[%v]: 回滚异常[%v]\n", err, rbErr)
-					return err
-				}
-			}
-			if err = sessionContext.CommitTransaction(sessionContext); err != nil {
-				log.CtxError(sessionContext, "设置评论属性: 提交事务异常[%v]\n", err)
-				return err
-			}
-			return nil
-		})
-	} else {
-		var oldComment *commentMapper.Comment
-		if oldComment, err = s.CommentMongoMapper.FindOne(ctx, res.TopCommentId); err != nil {
-			return resp, err
+// tombstoneComment 将评论置为墓碑：清空内容并扣减计数，其下的回复保持不变
+func (s *CommentService) tombstoneComment(ctx context.Context, data *commentMapper.Comment) (err error) {
+	commentId := data.ID.Hex()
+	var changes []*changeMapper.Change
+	if err = withTransaction(ctx, s.CommentMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+		var err1 error
+		if err1 = s.CommentMongoMapper.SoftDelete(sessionContext, commentId); err1 != nil {
+			log.CtxError(sessionContext, "删除评论： 产生错误[%v]\n", err1)
+			return err1
 		}
-		err = tx.UseSession(ctx, func(sessionContext mongo.SessionContext) error {
-			if err = sessionContext.StartTransaction(); err != nil {
-				return err
-			}
-			var subject *subjectMapper.Subject
-			switch {
-			case req.Attrs == int64(platform.Attrs_Pinned) || req.Attrs == int64(platform.Attrs_PinnedAndHighlighted):
-				subject = &subjectMapper.Subject{ID: subjectId, TopCommentId: lo.ToPtr(req.CommentId)}
-			default:
-				subject = &subjectMapper.Subject{ID: subjectId, TopCommentId: lo.ToPtr("")}
-			}
-			if _, err = s.SubjectMongoMapper.Update(sessionContext, subject); err != nil {
-				if rbErr := sessionContext.AbortTransaction(sessionContext); rbErr != nil {
-					log.CtxError(sessionContext, "设置评论属性失败[%v]: 回滚异常[%v]\n", err, rbErr)
+		// 删除前的内容移入回收站以便恢复，历史版本、表态与关联关系在清理时一并删除
+		if err1 = s.RecycleMongoMapper.InsertMany(sessionContext, []*recycleMapper.Recycle{{BatchId: commentId, Comment: data, Tombstone: true}}); err1 != nil {
+			log.CtxError(sessionContext, "移入回收站 产生错误[%v]\n", err1)
+			return err1
+		}
+		delta := countDelta(data.State, consts.Decrement)
+		if err1 = s.incrCount(sessionContext, data.SubjectId, data.RootId, delta, delta); err1 != nil {
+			log.CtxError(sessionContext, "更新评论数 产生错误[%v]\n", err1)
+			return err1
+		}
+		if changes, err1 = recordChanges(sessionContext, s.SubjectMongoMapper, s.ChangeMongoMapper, data.SubjectId, changeMapper.DeleteOp, data); err1 != nil {
+			log.CtxError(sessionContext, "记录评论变更 产生错误[%v]\n", err1)
+			return err1
+		}
+		return nil
+	}); err != nil {
+		log.CtxError(ctx, "删除评论 失败[%v]\n", err)
+		return err
+	}
+	data.State, data.Content, data.Meta, data.Labels, data.Mentions, data.Reactions = consts.DeletedState, "", "", nil, nil, nil
+	publishChanges(s.Bus, changes, data)
+	s.unindexComments(ctx, []string{commentId})
+	return nil
+}
 
-					return err
-				}
-			}
-			oid, _ := primitive.ObjectIDFromHex(res.TopCommentId)
-			oldData := &commentMapper.Comment{ID: oid, Attrs: int64(platform.Attrs_None), SortTime: oldComment.CreateAt.UnixMilli()}
-			if _, err = s.CommentMongoMapper.Update(sessionContext, oldData); err != nil {
-				if rbErr := sessionContext.AbortTransaction(sessionContext); rbErr != nil {
-					log.CtxError(sessionContext, "设置评论属性失败[%v]: 回滚异常[%v]\n", err, rbErr)
-					return err
-				}
-			}
-			if _, err = s.CommentMongoMapper.Update(sessionContext, data); err != nil {
-				if rbErr := sessionContext.AbortTransaction(sessionContext); rbErr != nil {
-					log.CtxError(sessionContext, "设置评论属性失败[%v]: 回滚异常[%v]\n", err, rbErr)
-				}
-				return err
-			}
-			if err = sessionContext.CommitTransaction(sessionContext); err != nil {
-				log.CtxError(sessionContext, "设置评论属性: 提交事务异常[%v]\n", err)
-				return err
-			}
-			return nil
-		})
+// SetCommentAttrs 设置评论属性，置顶标记的变化同步到评论区的置顶列表
+func (s *CommentService) SetCommentAttrs(ctx context.Context, req *platform.SetCommentAttrsReq) (resp *platform.SetCommentAttrsResp, err error) {
+	resp = new(platform.SetCommentAttrsResp)
+	if err = s.setPinned(ctx, req.SubjectId, req.CommentId, isPinned(req.Attrs), time.Time{}, req.Attrs); err != nil {
+		return resp, err
 	}
-	return resp, err
+	return resp, nil
 }
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/platform/biz/infrastructure/sort"
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"testing"
+)
+
+func TestReplyPreviewOptionsWithDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *ReplyPreviewOptions
+		want ReplyPreviewOptions
+	}{
+		{name: "未指定", want: ReplyPreviewOptions{Size: defaultReplyPreviewSize, SortMode: sort.NewestSortMode}},
+		{name: "条数为 0", opts: &ReplyPreviewOptions{SortMode: sort.HotSortMode}, want: ReplyPreviewOptions{Size: defaultReplyPreviewSize, SortMode: sort.HotSortMode}},
+		{name: "条数为负数", opts: &ReplyPreviewOptions{Size: -1}, want: ReplyPreviewOptions{Size: defaultReplyPreviewSize}},
+		{name: "指定条数", opts: &ReplyPreviewOptions{Size: 3}, want: ReplyPreviewOptions{Size: 3}},
+		{name: "超过上限", opts: &ReplyPreviewOptions{Size: 1000}, want: ReplyPreviewOptions{Size: maxReplyPreviewSize}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.withDefaults(); *got != tt.want {
+				t.Errorf("withDefaults() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplyPreviewOptionsFromContext(t *testing.T) {
+	ctx := metainfo.WithValue(context.Background(), ReplyPreviewSizeMetaKey, "5")
+	ctx = metainfo.WithValue(ctx, sort.ReplySortModeMetaKey, "1")
+	want := ReplyPreviewOptions{Size: 5, SortMode: sort.HotSortMode}
+	if got := ReplyPreviewOptionsFromContext(ctx); *got != want {
+		t.Errorf("ReplyPreviewOptionsFromContext() = %+v, want %+v", *got, want)
+	}
+	if got := ReplyPreviewOptionsFromContext(context.Background()).withDefaults(); got.Size != defaultReplyPreviewSize {
+		t.Errorf("未携带条数时 Size = %d, want %d", got.Size, defaultReplyPreviewSize)
+	}
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"github.com/CloudStriver/platform/biz/infrastructure/convertor"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform"
+	"github.com/samber/lo"
+)
+
+// CommentDetail 网关接口返回的评论，在 platform.Comment 之外附带 IDL 中还没有的字段
+type CommentDetail struct {
+	*platform.Comment
+	// Edited 发布后是否编辑过，EditTime 为最后一次编辑的时间，单位毫秒
+	Edited   bool  `json:"edited"`
+	EditTime int64 `json:"editTime,omitempty"`
+	// Mentions 内容中提及的用户，客户端按 Offset 与 Length（字符数）渲染
+	Mentions []commentMapper.Mention `json:"mentions,omitempty"`
+}
+
+// CommentDetailList 网关接口分页返回的评论列表
+type CommentDetailList struct {
+	Comments []*CommentDetail `json:"comments"`
+	Total    int64            `json:"total"`
+	Token    string           `json:"token"`
+}
+
+func toCommentDetail(data *commentMapper.Comment) *CommentDetail {
+	detail := &CommentDetail{Comment: convertor.CommentMapperToComment(data), Edited: !data.EditAt.IsZero(), Mentions: data.Mentions}
+	if detail.Edited {
+		detail.EditTime = data.EditAt.UnixMilli()
+	}
+	return detail
+}
+
+func toCommentDetails(comments []*commentMapper.Comment) []*CommentDetail {
+	return lo.Map(comments, func(comment *commentMapper.Comment, _ int) *CommentDetail {
+		return toCommentDetail(comment)
+	})
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestToCommentDetail(t *testing.T) {
+	editAt := time.UnixMilli(1700000000123)
+	tests := []struct {
+		name         string
+		editAt       time.Time
+		mentions     []commentMapper.Mention
+		wantEdited   bool
+		wantEditTime int64
+	}{
+		{name: "未编辑", wantEdited: false},
+		{name: "编辑过", editAt: editAt, wantEdited: true, wantEditTime: editAt.UnixMilli()},
+		{name: "提及", mentions: []commentMapper.Mention{{UserId: "65a1b2c3d4e5f6a7b8c9d0e1", Offset: 2, Length: 25}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := &commentMapper.Comment{ID: primitive.NewObjectID(), Count: lo.ToPtr[int64](0), EditAt: tt.editAt, Mentions: tt.mentions}
+			got := toCommentDetail(data)
+			if got.CommentId != data.ID.Hex() || got.Edited != tt.wantEdited || got.EditTime != tt.wantEditTime {
+				t.Errorf("toCommentDetail() = %+v, want edited %v at %d", got, tt.wantEdited, tt.wantEditTime)
+			}
+			if !reflect.DeepEqual(got.Mentions, tt.mentions) {
+				t.Errorf("Mentions = %v, want %v", got.Mentions, tt.mentions)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	changeMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/change"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	moderationMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/moderation"
+	outboxMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/outbox"
+	reactionMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/reaction"
+	recycleMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/recycle"
+	revisionMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/revision"
+	"github.com/google/wire"
+)
+
+type IIndexService interface {
+	EnsureIndexes(ctx context.Context) (err error)
+}
+
+type IndexService struct {
+	CommentMongoMapper    commentMapper.IMongoMapper
+	ReactionMongoMapper   reactionMapper.IMongoMapper
+	OutboxMongoMapper     outboxMapper.IMongoMapper
+	ChangeMongoMapper     changeMapper.IMongoMapper
+	RecycleMongoMapper    recycleMapper.IMongoMapper
+	RevisionMongoMapper   revisionMapper.IMongoMapper
+	ModerationMongoMapper moderationMapper.IMongoMapper
+}
+
+var IndexSet = wire.NewSet(
+	wire.Struct(new(IndexService), "*"),
+	wire.Bind(new(IIndexService), new(*IndexService)),
+)
+
+// EnsureIndexes 创建各集合的查询索引，定义相同的索引已存在时不做处理
+func (s *IndexService) EnsureIndexes(ctx context.Context) (err error) {
+	for _, m := range []struct {
+		name   string
+		mapper interface {
+			EnsureIndexes(ctx context.Context) error
+		}
+	}{
+		{commentMapper.CollectionName, s.CommentMongoMapper},
+		{reactionMapper.CollectionName, s.ReactionMongoMapper},
+		{outboxMapper.CollectionName, s.OutboxMongoMapper},
+		{changeMapper.CollectionName, s.ChangeMongoMapper},
+		{recycleMapper.CollectionName, s.RecycleMongoMapper},
+		{revisionMapper.CollectionName, s.RevisionMongoMapper},
+		{moderationMapper.CollectionName, s.ModerationMongoMapper},
+	} {
+		if err = m.mapper.EnsureIndexes(ctx); err != nil {
+			log.CtxError(ctx, "创建集合[%s]索引 失败[%v]\n", m.name, err)
+			return err
+		}
+	}
+	return nil
+}
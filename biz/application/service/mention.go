@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/pconvertor"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/kq"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/bytedance/sonic"
+	"github.com/samber/lo"
+	"regexp"
+	"unicode/utf8"
+)
+
+// maxMentionEvents 单条评论最多通知的被提及用户数
+const maxMentionEvents = 20
+
+// mentionPattern 内容中以 @ 加用户 id 的形式提及用户
+var mentionPattern = regexp.MustCompile(`@([0-9a-f]{24})`)
+
+// parseMentions 解析内容中的全部提及，偏移量按字符计以便客户端直接定位
+func parseMentions(content string) []commentMapper.Mention {
+	return lo.Map(mentionPattern.FindAllStringSubmatchIndex(content, -1), func(loc []int, _ int) commentMapper.Mention {
+		return commentMapper.Mention{
+			UserId: content[loc[2]:loc[3]],
+			Offset: int64(utf8.RuneCountInString(content[:loc[0]])),
+			Length: int64(utf8.RuneCountInString(content[loc[0]:loc[1]])),
+		}
+	})
+}
+
+// pushMentions 为每个被提及的用户发送一条事件，跳过作者本人与 notified 中已经通知过的用户，未配置提及消息队列时不发送
+func (s *CommentService) pushMentions(ctx context.Context, data *commentMapper.Comment, notified []string) {
+	if s.CommentMentionKq == nil || lo.Contains(consts.InvisibleStates, data.State) {
+		return
+	}
+	userIds := lo.Uniq(lo.Map(data.Mentions, func(mention commentMapper.Mention, _ int) string {
+		return mention.UserId
+	}))
+	userIds = lo.Without(userIds, append(notified, data.UserId)...)
+	for _, userId := range lo.Subset(userIds, 0, maxMentionEvents) {
+		msg, _ := sonic.Marshal(&kq.CommentMentionMessage{
+			CommentId:       data.ID.Hex(),
+			SubjectId:       data.SubjectId,
+			UserId:          data.UserId,
+			MentionedUserId: userId,
+			Type:            data.Type,
+		})
+		if err := s.CommentMentionKq.Push(pconvertor.Bytes2String(msg)); err != nil {
+			log.CtxError(ctx, "发送评论提及消息 失败[%v]\n", err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	const (
+		alice = "65a1b2c3d4e5f6a7b8c9d0e1"
+		bob   = "65a1b2c3d4e5f6a7b8c9d0e2"
+	)
+	tests := []struct {
+		name    string
+		content string
+		want    []commentMapper.Mention
+	}{
+		{name: "没有提及", content: "普通评论"},
+		{name: "开头提及", content: "@" + alice + " 你好", want: []commentMapper.Mention{{UserId: alice, Offset: 0, Length: 25}}},
+		{name: "偏移量按字符计", content: "你好@" + alice, want: []commentMapper.Mention{{UserId: alice, Offset: 2, Length: 25}}},
+		{name: "多个提及", content: "@" + alice + "和@" + bob, want: []commentMapper.Mention{
+			{UserId: alice, Offset: 0, Length: 25},
+			{UserId: bob, Offset: 26, Length: 25},
+		}},
+		{name: "重复提及全部返回", content: "@" + alice + "@" + alice, want: []commentMapper.Mention{
+			{UserId: alice, Offset: 0, Length: 25},
+			{UserId: alice, Offset: 25, Length: 25},
+		}},
+		{name: "id 长度不足", content: "@65a1b2c3"},
+		{name: "大写字母不是合法 id", content: "@65A1B2C3D4E5F6A7B8C9D0E1"},
+		{name: "只取前 24 位", content: "@" + alice + "ff", want: []commentMapper.Mention{{UserId: alice, Offset: 0, Length: 25}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseMentions(tt.content)
+			if len(got) != len(tt.want) || len(got) > 0 && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMentions(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushMentionsWithoutKq(t *testing.T) {
+	s := &CommentService{}
+	// 未配置提及消息队列时直接跳过，不应访问空的 pusher
+	s.pushMentions(context.Background(), &commentMapper.Comment{Mentions: []commentMapper.Mention{{UserId: "65a1b2c3d4e5f6a7b8c9d0e1"}}}, nil)
+}
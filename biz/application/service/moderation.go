@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/pagination/mongop"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/convertor"
+	changeMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/change"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	moderationMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/moderation"
+	"github.com/CloudStriver/platform/biz/infrastructure/sort"
+	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/basic"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// moderationTransitions 评论审核状态机：当前状态 -> 允许变更到的状态
+var moderationTransitions = map[int64][]int64{
+	consts.PendingState:  {consts.NormalState, consts.RejectedState},
+	consts.NormalState:   {consts.FoldedState, consts.HiddenState, consts.RejectedState},
+	consts.FoldedState:   {consts.NormalState, consts.HiddenState, consts.RejectedState},
+	consts.HiddenState:   {consts.NormalState, consts.FoldedState, consts.RejectedState},
+	consts.RejectedState: {consts.NormalState},
+}
+
+// canModerate 判断审核状态机是否允许评论从 from 变更到 to
+func canModerate(from, to int64) bool {
+	return lo.Contains(moderationTransitions[from], to)
+}
+
+// ModerationLog 评论的一次状态变更记录
+type ModerationLog struct {
+	CommentId  string `json:"commentId"`
+	OperatorId string `json:"operatorId"`
+	FromState  int64  `json:"fromState"`
+	ToState    int64  `json:"toState"`
+	Reason     string `json:"reason"`
+	CreateTime int64  `json:"createTime"`
+}
+
+type GetModerationLogsResp struct {
+	Logs  []*ModerationLog `json:"logs"`
+	Total int64            `json:"total"`
+	Token string           `json:"token"`
+}
+
+// ModerateComment 按审核状态机变更评论状态，记录操作人并同步调整计数
+func (s *CommentService) ModerateComment(ctx context.Context, commentId, operatorId string, state int64, reason string) (err error) {
+	var data *commentMapper.Comment
+	if data, err = s.CommentMongoMapper.FindOne(ctx, commentId); err != nil {
+		log.CtxError(ctx, "获取评论详情 失败[%v]\n", err)
+		return err
+	}
+	if data.State == state {
+		return nil
+	}
+	if !canModerate(data.State, state) {
+		return consts.ErrInvalidStateChange
+	}
+
+	var changes []*changeMapper.Change
+	if err = withTransaction(ctx, s.CommentMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+		var err1 error
+		// 读取之后状态可能已被其他审核操作修改，按读取到的状态条件更新，避免重复调整计数
+		if err1 = s.CommentMongoMapper.UpdateState(sessionContext, commentId, data.State, state); err1 != nil {
+			log.CtxError(sessionContext, "变更评论状态 产生错误[%v]\n", err1)
+			return err1
+		}
+		if _, err1 = s.ModerationMongoMapper.Insert(sessionContext, &moderationMapper.Moderation{
+			CommentId:  commentId,
+			SubjectId:  data.SubjectId,
+			OperatorId: operatorId,
+			FromState:  data.State,
+			ToState:    state,
+			Reason:     reason,
+		}); err1 != nil {
+			log.CtxError(sessionContext, "记录审核操作 产生错误[%v]\n", err1)
+			return err1
+		}
+		if delta := countDelta(state, consts.Increment) - countDelta(data.State, consts.Increment); delta != 0 {
+			if err1 = s.incrCount(sessionContext, data.SubjectId, data.RootId, delta, delta); err1 != nil {
+				log.CtxError(sessionContext, "更新评论数 产生错误[%v]\n", err1)
+				return err1
+			}
+		}
+		if changes, err1 = recordChanges(sessionContext, s.SubjectMongoMapper, s.ChangeMongoMapper, data.SubjectId, changeMapper.StateOp, data); err1 != nil {
+			log.CtxError(sessionContext, "记录评论变更 产生错误[%v]\n", err1)
+			return err1
+		}
+		return nil
+	}); err != nil {
+		log.CtxError(ctx, "变更评论状态 失败[%v]\n", err)
+		return err
+	}
+	from := data.State
+	data.State = state
+	publishChanges(s.Bus, changes, data)
+	s.indexComment(ctx, data)
+	// 先审后发的评论在审核通过时才通知被提及的用户
+	if from == consts.PendingState {
+		s.pushMentions(ctx, data, nil)
+	}
+	return nil
+}
+
+// GetModerationQueue 按状态列出待处理的评论，state 为 0 时列出待审核评论，subjectId 为空时不限评论区
+func (s *CommentService) GetModerationQueue(ctx context.Context, subjectId *string, state int64, pagination *basic.PaginationOptions) (resp *CommentDetailList, err error) {
+	resp = new(CommentDetailList)
+	var comments []*commentMapper.Comment
+	if state == 0 {
+		state = consts.PendingState
+	}
+
+	p := convertor.ParsePagination(pagination)
+	filter := &commentMapper.FilterOptions{OnlySubjectId: subjectId, OnlyState: lo.ToPtr(state)}
+	if comments, resp.Total, err = s.CommentMongoMapper.FindManyAndCount(ctx, filter, p, sort.TimeCursorType); err != nil {
+		log.CtxError(ctx, "获取审核队列 失败[%v]\n", err)
+		return resp, err
+	}
+	if p.LastToken != nil {
+		resp.Token = *p.LastToken
+	}
+	resp.Comments = toCommentDetails(comments)
+	return resp, nil
+}
+
+func (s *CommentService) GetModerationLogs(ctx context.Context, commentId string, pagination *basic.PaginationOptions) (resp *GetModerationLogsResp, err error) {
+	resp = new(GetModerationLogsResp)
+	var logs []*moderationMapper.Moderation
+	p := convertor.ParsePagination(pagination)
+	if logs, resp.Total, err = s.ModerationMongoMapper.FindManyAndCount(ctx, commentId, p, mongop.IdCursorType); err != nil {
+		log.CtxError(ctx, "获取审核记录 失败[%v]\n", err)
+		return resp, err
+	}
+	if p.LastToken != nil {
+		resp.Token = *p.LastToken
+	}
+	resp.Logs = lo.Map(logs, func(item *moderationMapper.Moderation, _ int) *ModerationLog {
+		return &ModerationLog{
+			CommentId:  item.CommentId,
+			OperatorId: item.OperatorId,
+			FromState:  item.FromState,
+			ToState:    item.ToState,
+			Reason:     item.Reason,
+			CreateTime: item.CreateAt.UnixMilli(),
+		}
+	})
+	return resp, nil
+}
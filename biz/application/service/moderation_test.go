@@ -0,0 +1,81 @@
+package service
+
+import (
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"testing"
+)
+
+func TestCanModerate(t *testing.T) {
+	tests := []struct {
+		name string
+		from int64
+		to   int64
+		want bool
+	}{
+		{name: "审核通过", from: consts.PendingState, to: consts.NormalState, want: true},
+		{name: "审核不通过", from: consts.PendingState, to: consts.RejectedState, want: true},
+		{name: "待审核不能直接折叠", from: consts.PendingState, to: consts.FoldedState},
+		{name: "折叠", from: consts.NormalState, to: consts.FoldedState, want: true},
+		{name: "隐藏", from: consts.NormalState, to: consts.HiddenState, want: true},
+		{name: "不能退回待审核", from: consts.NormalState, to: consts.PendingState},
+		{name: "取消折叠", from: consts.FoldedState, to: consts.NormalState, want: true},
+		{name: "取消隐藏", from: consts.HiddenState, to: consts.NormalState, want: true},
+		{name: "复审通过", from: consts.RejectedState, to: consts.NormalState, want: true},
+		{name: "不通过后不能隐藏", from: consts.RejectedState, to: consts.HiddenState},
+		{name: "已删除的评论不能审核", from: consts.DeletedState, to: consts.NormalState},
+		{name: "不能通过审核删除", from: consts.NormalState, to: consts.DeletedState},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canModerate(tt.from, tt.to); got != tt.want {
+				t.Errorf("canModerate(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountDelta(t *testing.T) {
+	tests := []struct {
+		name  string
+		state int64
+		delta int64
+		want  int64
+	}{
+		{name: "正常评论增加", state: consts.NormalState, delta: consts.Increment, want: 1},
+		{name: "正常评论减少", state: consts.NormalState, delta: consts.Decrement, want: -1},
+		{name: "折叠评论计入计数", state: consts.FoldedState, delta: consts.Decrement, want: -1},
+		{name: "待审核评论不计入", state: consts.PendingState, delta: consts.Increment, want: 0},
+		{name: "隐藏评论不计入", state: consts.HiddenState, delta: consts.Decrement, want: 0},
+		{name: "审核不通过不计入", state: consts.RejectedState, delta: consts.Increment, want: 0},
+		{name: "墓碑不计入", state: consts.DeletedState, delta: consts.Decrement, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countDelta(tt.state, tt.delta); got != tt.want {
+				t.Errorf("countDelta(%d, %d) = %d, want %d", tt.state, tt.delta, got, tt.want)
+			}
+		})
+	}
+}
+
+// 审核在两个状态之间变更时，计数的调整量等于两个状态各自计数的差
+func TestModerationCountDelta(t *testing.T) {
+	tests := []struct {
+		name string
+		from int64
+		to   int64
+		want int64
+	}{
+		{name: "审核通过加入计数", from: consts.PendingState, to: consts.NormalState, want: 1},
+		{name: "隐藏移出计数", from: consts.NormalState, to: consts.HiddenState, want: -1},
+		{name: "折叠不影响计数", from: consts.NormalState, to: consts.FoldedState, want: 0},
+		{name: "不计入的状态之间不影响计数", from: consts.HiddenState, to: consts.RejectedState, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countDelta(tt.to, consts.Increment) - countDelta(tt.from, consts.Increment); got != tt.want {
+				t.Errorf("delta(%d -> %d) = %d, want %d", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/CloudStriver/cloudmind-mq/app/util/message"
+	"github.com/CloudStriver/go-pkg/utils/pconvertor"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/kq"
+	outboxMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/outbox"
+	"github.com/bytedance/sonic"
+	"github.com/google/wire"
+	"time"
+)
+
+// TopicDeleteCommentRelation 删除评论或评论区关联关系的消息，投递到 DeleteCommentRelationKq
+const TopicDeleteCommentRelation = "deleteCommentRelation"
+
+type IOutboxService interface {
+	Dispatch(ctx context.Context) (delivered int64, err error)
+	RunDispatch(ctx context.Context)
+}
+
+type OutboxService struct {
+	Config                  *config.Config
+	OutboxMongoMapper       outboxMapper.IMongoMapper
+	DeleteCommentRelationKq *kq.DeleteCommentRelationKq
+}
+
+var OutboxSet = wire.NewSet(
+	wire.Struct(new(OutboxService), "*"),
+	wire.Bind(new(IOutboxService), new(*OutboxService)),
+)
+
+// newDeleteRelationOutbox 生成删除关联关系的待发送消息，调用方需要在删除数据的事务中写入
+func newDeleteRelationOutbox(fromType int64, fromId string) *outboxMapper.Outbox {
+	data, _ := sonic.Marshal(&message.DeleteCommentRelationsMessage{
+		FromType: fromType,
+		FromId:   fromId,
+	})
+	return &outboxMapper.Outbox{
+		Topic:   TopicDeleteCommentRelation,
+		Payload: pconvertor.Bytes2String(data),
+	}
+}
+
+// Dispatch 投递全部到期的消息，失败的消息按指数退避重新安排，超过最大重试次数后标记为失败
+// 消息先被认领再投递，认领期间其他实例不会重复投递；认领超时或确认前中断时仍可能重复，消费方需要保证幂等
+func (s *OutboxService) Dispatch(ctx context.Context) (delivered int64, err error) {
+	conf := s.Config.Outbox
+	// 退避时长不为正数时失败的消息会被立即重新认领，拒绝执行
+	if conf.BatchSize <= 0 || conf.Lease <= 0 || conf.Backoff <= 0 || conf.MaxBackoff <= 0 {
+		return 0, consts.ErrComponentNotStarted
+	}
+	var messages []*outboxMapper.Outbox
+	for {
+		if messages, err = s.OutboxMongoMapper.ClaimDue(ctx, conf.Lease, conf.BatchSize); err != nil {
+			log.CtxError(ctx, "认领待投递消息 失败[%v]\n", err)
+			return delivered, err
+		}
+		if len(messages) == 0 {
+			return delivered, nil
+		}
+		for _, msg := range messages {
+			if err1 := s.push(ctx, msg); err1 != nil {
+				attempts := msg.Attempts + 1
+				state := outboxMapper.PendingState
+				if conf.MaxAttempts > 0 && attempts >= conf.MaxAttempts {
+					state = outboxMapper.FailedState
+					log.CtxError(ctx, "投递消息[%s] 超过最大重试次数[%d]: %v\n", msg.ID.Hex(), attempts, err1)
+				}
+				if err = s.OutboxMongoMapper.MarkRetry(ctx, msg.ID, state, attempts, time.Now().Add(backoff(attempts, conf.Backoff, conf.MaxBackoff)), err1.Error()); err != nil {
+					log.CtxError(ctx, "记录消息投递失败 失败[%v]\n", err)
+					return delivered, err
+				}
+				continue
+			}
+			if err = s.OutboxMongoMapper.MarkDelivered(ctx, msg.ID); err != nil {
+				log.CtxError(ctx, "标记消息已投递 失败[%v]\n", err)
+				return delivered, err
+			}
+			delivered++
+		}
+	}
+}
+
+func (s *OutboxService) push(ctx context.Context, msg *outboxMapper.Outbox) error {
+	switch msg.Topic {
+	case TopicDeleteCommentRelation:
+		return s.DeleteCommentRelationKq.Push(ctx, msg.Payload)
+	default:
+		return fmt.Errorf("未知的消息主题[%s]", msg.Topic)
+	}
+}
+
+// RunDispatch 按配置的间隔定期投递消息，直到 ctx 结束
+func (s *OutboxService) RunDispatch(ctx context.Context) {
+	if s.Config.Outbox.Interval <= 0 {
+		log.CtxError(ctx, "消息投递配置无效，不启动投递: 间隔[%v]\n", s.Config.Outbox.Interval)
+		return
+	}
+	ticker := time.NewTicker(s.Config.Outbox.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if delivered, err := s.Dispatch(ctx); err != nil {
+				log.CtxError(ctx, "投递消息 失败[%v]\n", err)
+			} else if delivered > 0 {
+				log.CtxInfo(ctx, "投递消息: 成功[%d]\n", delivered)
+			}
+		}
+	}
+}
+
+// backoff 第 attempts 次失败后的等待时长，从 base 开始每次翻倍，不超过 max
+func backoff(attempts int64, base, max time.Duration) time.Duration {
+	d := base
+	for i := int64(1); i < attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
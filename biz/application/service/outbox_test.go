@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int64
+		base     time.Duration
+		max      time.Duration
+		want     time.Duration
+	}{
+		{name: "首次失败", attempts: 1, base: time.Second, max: time.Minute, want: time.Second},
+		{name: "每次翻倍", attempts: 2, base: time.Second, max: time.Minute, want: 2 * time.Second},
+		{name: "第四次失败", attempts: 4, base: time.Second, max: time.Minute, want: 8 * time.Second},
+		{name: "不超过上限", attempts: 10, base: time.Second, max: time.Minute, want: time.Minute},
+		{name: "重试次数很大时不溢出", attempts: 1000, base: time.Second, max: time.Minute, want: time.Minute},
+		{name: "起始等待超过上限", attempts: 1, base: time.Hour, max: time.Minute, want: time.Minute},
+		{name: "未失败", attempts: 0, base: time.Second, max: time.Minute, want: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backoff(tt.attempts, tt.base, tt.max); got != tt.want {
+				t.Errorf("backoff(%d, %v, %v) = %v, want %v", tt.attempts, tt.base, tt.max, got, tt.want)
+			}
+		})
+	}
+}
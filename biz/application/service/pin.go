@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	subjectMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/subject"
+	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// defaultPinLimit 未配置或配置无效时每个评论区最多置顶的评论数
+const defaultPinLimit = 3
+
+// pinLimit 返回每个评论区最多置顶的评论数，配置不为正数时使用 defaultPinLimit
+func (s *CommentService) pinLimit() int64 {
+	if s.Config.Pin.Limit <= 0 {
+		return defaultPinLimit
+	}
+	return s.Config.Pin.Limit
+}
+
+// PinComment 置顶一级评论，已置顶时只更新过期时间，expireAt 为 0 表示永久置顶
+func (s *CommentService) PinComment(ctx context.Context, subjectId, commentId string, expireAt int64) (err error) {
+	var expire time.Time
+	if expireAt > 0 {
+		expire = time.UnixMilli(expireAt)
+	}
+	return s.setPinned(ctx, subjectId, commentId, true, expire, 0)
+}
+
+func (s *CommentService) UnpinComment(ctx context.Context, subjectId, commentId string) (err error) {
+	return s.setPinned(ctx, subjectId, commentId, false, time.Time{}, 0)
+}
+
+// ReorderPins 按 commentIds 的顺序重排置顶评论，commentIds 必须恰好是当前全部置顶评论
+func (s *CommentService) ReorderPins(ctx context.Context, subjectId string, commentIds []string) (err error) {
+	var (
+		pins    []subjectMapper.Pin
+		updates map[string]*commentMapper.Comment
+	)
+	if pins, updates, err = s.loadPins(ctx, subjectId); err != nil {
+		return err
+	}
+	byId := lo.KeyBy(pins, func(pin subjectMapper.Pin) string {
+		return pin.CommentId
+	})
+	if len(lo.Uniq(commentIds)) != len(pins) || !lo.EveryBy(commentIds, func(id string) bool {
+		_, ok := byId[id]
+		return ok
+	}) {
+		return consts.ErrIllegalOperation
+	}
+	pins = lo.Map(commentIds, func(id string, _ int) subjectMapper.Pin {
+		return byId[id]
+	})
+	return s.updatePins(ctx, subjectId, pins, updates)
+}
+
+// setPinned 置顶或取消置顶评论，attrs 不为 0 时同时把评论属性设为 attrs，否则只切换置顶并保留精华属性
+func (s *CommentService) setPinned(ctx context.Context, subjectId, commentId string, pinned bool, expire time.Time, attrs int64) (err error) {
+	var (
+		data    *commentMapper.Comment
+		pins    []subjectMapper.Pin
+		updates map[string]*commentMapper.Comment
+	)
+	if data, err = s.CommentMongoMapper.FindOne(ctx, commentId); err != nil {
+		log.CtxError(ctx, "获取评论详情 失败[%v]\n", err)
+		return err
+	}
+	if data.SubjectId != subjectId {
+		return consts.ErrIllegalOperation
+	}
+	if pins, updates, err = s.loadPins(ctx, subjectId); err != nil {
+		return err
+	}
+	if attrs == 0 {
+		attrs = withPinned(data.Attrs, pinned)
+	}
+
+	_, index, found := lo.FindIndexOf(pins, func(pin subjectMapper.Pin) bool {
+		return pin.CommentId == commentId
+	})
+	switch {
+	case pinned && (data.RootId != data.SubjectId || data.State != consts.NormalState && data.State != consts.FoldedState):
+		// 只有对外可见的一级评论可以置顶
+		return consts.ErrIllegalOperation
+	case pinned && found:
+		pins[index].ExpireAt = expire
+	case pinned:
+		if int64(len(pins)) >= s.pinLimit() {
+			return consts.ErrPinLimit
+		}
+		pins = append(pins, subjectMapper.Pin{CommentId: commentId, PinAt: time.Now(), ExpireAt: expire})
+	case found:
+		pins = append(pins[:index], pins[index+1:]...)
+	}
+	if update, ok := updates[commentId]; ok {
+		update.Attrs = attrs
+	} else {
+		updates[commentId] = &commentMapper.Comment{ID: data.ID, Attrs: attrs}
+	}
+	return s.updatePins(ctx, subjectId, pins, updates)
+}
+
+// loadPins 读取评论区当前有效的置顶列表，并为已过期的置顶与旧版 topCommentId 生成需要回写的评论属性
+func (s *CommentService) loadPins(ctx context.Context, subjectId string) (pins []subjectMapper.Pin, updates map[string]*commentMapper.Comment, err error) {
+	var subject *subjectMapper.Subject
+	if subject, err = s.SubjectMongoMapper.FindOne(ctx, subjectId); err != nil {
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return nil, nil, err
+	}
+	// 读取置顶列表都是为了修改，归档的评论区不允许变更置顶与评论属性
+	if subject.State == consts.SubjectArchivedState {
+		return nil, nil, consts.ErrSubjectArchived
+	}
+
+	updates = make(map[string]*commentMapper.Comment)
+	if len(subject.Pins) == 0 && lo.FromPtr(subject.TopCommentId) != "" {
+		// 旧版置顶通过把 sortTime 调到最大实现，迁移时恢复为创建时间
+		var data *commentMapper.Comment
+		if data, err = s.CommentMongoMapper.FindOne(ctx, *subject.TopCommentId); err == nil {
+			subject.Pins = []subjectMapper.Pin{{CommentId: *subject.TopCommentId, PinAt: subject.UpdateAt}}
+			updates[data.ID.Hex()] = &commentMapper.Comment{ID: data.ID, SortTime: data.CreateAt.UnixMilli()}
+		} else if !errors.Is(err, consts.ErrNotFound) {
+			return nil, nil, err
+		}
+	}
+
+	// 已被删除的评论与过期的置顶一并移出置顶列表，过期评论的置顶标记在保存时清除
+	var comments []*commentMapper.Comment
+	if comments, err = s.CommentMongoMapper.FindAll(ctx, &commentMapper.FilterOptions{
+		OnlyCommentIds: lo.Map(subject.Pins, func(pin subjectMapper.Pin, _ int) string { return pin.CommentId }),
+	}); err != nil {
+		log.CtxError(ctx, "获取置顶评论 失败[%v]\n", err)
+		return nil, nil, err
+	}
+	byId := lo.KeyBy(comments, func(comment *commentMapper.Comment) string {
+		return comment.ID.Hex()
+	})
+	active := lo.SliceToMap(subject.ActivePins(time.Now()), func(pin subjectMapper.Pin) (string, struct{}) {
+		return pin.CommentId, struct{}{}
+	})
+	for _, pin := range subject.Pins {
+		data, ok := byId[pin.CommentId]
+		if !ok || data.State == consts.DeletedState {
+			continue
+		}
+		if _, ok = active[pin.CommentId]; ok {
+			pins = append(pins, pin)
+		} else {
+			updates[pin.CommentId] = &commentMapper.Comment{ID: data.ID, Attrs: withPinned(data.Attrs, false)}
+		}
+	}
+	return pins, updates, nil
+}
+
+// updatePins 在同一事务中保存置顶列表并回写相关评论的属性
+func (s *CommentService) updatePins(ctx context.Context, subjectId string, pins []subjectMapper.Pin, updates map[string]*commentMapper.Comment) (err error) {
+	if err = withTransaction(ctx, s.SubjectMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+		var err1 error
+		if err1 = s.SubjectMongoMapper.SetPins(sessionContext, subjectId, pins); err1 != nil {
+			log.CtxError(sessionContext, "设置置顶评论 产生错误[%v]\n", err1)
+			return err1
+		}
+		for _, data := range updates {
+			if _, err1 = s.CommentMongoMapper.Update(sessionContext, data); err1 != nil {
+				log.CtxError(sessionContext, "设置评论属性 产生错误[%v]\n", err1)
+				return err1
+			}
+		}
+		return nil
+	}); err != nil {
+		log.CtxError(ctx, "设置置顶评论 失败[%v]\n", err)
+		return err
+	}
+	return nil
+}
+
+// findPinned 按置顶顺序返回评论区中满足 filter 的置顶评论，以及需要从普通列表中排除的全部置顶评论 id
+func (s *CommentService) findPinned(ctx context.Context, subjectId string, filter *commentMapper.FilterOptions) (pinned []*commentMapper.Comment, ids []string, err error) {
+	var subject *subjectMapper.Subject
+	if subject, err = s.SubjectMongoMapper.FindOne(ctx, subjectId); err != nil {
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return nil, nil, err
+	}
+	ids = lo.Map(subject.ActivePins(time.Now()), func(pin subjectMapper.Pin, _ int) string {
+		return pin.CommentId
+	})
+	if len(subject.Pins) == 0 && lo.FromPtr(subject.TopCommentId) != "" {
+		ids = []string{*subject.TopCommentId}
+	}
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+
+	pinFilter := *filter
+	pinFilter.OnlyCommentIds = ids
+	var comments []*commentMapper.Comment
+	if comments, err = s.CommentMongoMapper.FindAll(ctx, &pinFilter); err != nil {
+		log.CtxError(ctx, "获取置顶评论 失败[%v]\n", err)
+		return nil, nil, err
+	}
+	byId := lo.KeyBy(comments, func(comment *commentMapper.Comment) string {
+		return comment.ID.Hex()
+	})
+	pinned = lo.FilterMap(ids, func(id string, _ int) (*commentMapper.Comment, bool) {
+		comment, ok := byId[id]
+		return comment, ok
+	})
+	return pinned, ids, nil
+}
+
+// withPinned 切换评论属性中的置顶标记，保留精华标记
+func withPinned(attrs int64, pinned bool) int64 {
+	highlighted := attrs == int64(platform.Attrs_Highlighted) || attrs == int64(platform.Attrs_PinnedAndHighlighted)
+	switch {
+	case pinned && highlighted:
+		return int64(platform.Attrs_PinnedAndHighlighted)
+	case pinned:
+		return int64(platform.Attrs_Pinned)
+	case highlighted:
+		return int64(platform.Attrs_Highlighted)
+	default:
+		return int64(platform.Attrs_None)
+	}
+}
+
+func isPinned(attrs int64) bool {
+	return attrs == int64(platform.Attrs_Pinned) || attrs == int64(platform.Attrs_PinnedAndHighlighted)
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	reactionMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/reaction"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strconv"
+)
+
+// CommentReactions 一条评论的各类表态数量，以及当前用户的表态类型（未表态时为 0）
+type CommentReactions struct {
+	CommentId string          `json:"commentId"`
+	Counts    map[int64]int64 `json:"counts"`
+	Mine      int64           `json:"mine"`
+}
+
+// React 设置用户对评论的表态，已有其他表态时替换为新的类型
+func (s *CommentService) React(ctx context.Context, commentId, userId string, kind int64) (err error) {
+	if !consts.IsReactionKind(kind) {
+		return consts.ErrIllegalOperation
+	}
+	if _, err = s.findReactable(ctx, commentId); err != nil {
+		return err
+	}
+	return s.updateReaction(ctx, commentId, func(sessionContext mongo.SessionContext) (int64, int64, error) {
+		old, err1 := s.ReactionMongoMapper.Upsert(sessionContext, commentId, userId, kind)
+		return old, kind, err1
+	})
+}
+
+// Unreact 取消用户对评论的表态，与 React 一样只能在对外可见的评论上操作
+func (s *CommentService) Unreact(ctx context.Context, commentId, userId string) (err error) {
+	if _, err = s.findReactable(ctx, commentId); err != nil {
+		return err
+	}
+	return s.updateReaction(ctx, commentId, func(sessionContext mongo.SessionContext) (int64, int64, error) {
+		old, err1 := s.ReactionMongoMapper.Delete(sessionContext, commentId, userId)
+		return old, 0, err1
+	})
+}
+
+// findReactable 获取可以表态的评论，墓碑与不展示的评论不能表态或取消表态
+func (s *CommentService) findReactable(ctx context.Context, commentId string) (*commentMapper.Comment, error) {
+	data, err := s.CommentMongoMapper.FindOne(ctx, commentId)
+	if err != nil {
+		log.CtxError(ctx, "获取评论详情 失败[%v]\n", err)
+		return nil, err
+	}
+	if data.State == consts.DeletedState || lo.Contains(consts.InvisibleStates, data.State) {
+		return nil, consts.ErrIllegalOperation
+	}
+	return data, nil
+}
+
+// GetCommentReactions 批量返回评论的表态数量，userId 不为空时同时返回该用户在每条评论上的表态
+func (s *CommentService) GetCommentReactions(ctx context.Context, userId string, commentIds []string) (resp []*CommentReactions, err error) {
+	var (
+		comments  []*commentMapper.Comment
+		reactions []*reactionMapper.Reaction
+	)
+	if comments, err = s.CommentMongoMapper.FindAll(ctx, &commentMapper.FilterOptions{OnlyCommentIds: commentIds}); err != nil {
+		log.CtxError(ctx, "获取评论列表 失败[%v]\n", err)
+		return nil, err
+	}
+	if userId != "" {
+		if reactions, err = s.ReactionMongoMapper.FindByUser(ctx, userId, commentIds); err != nil {
+			log.CtxError(ctx, "获取用户表态 失败[%v]\n", err)
+			return nil, err
+		}
+	}
+
+	counts := lo.SliceToMap(comments, func(comment *commentMapper.Comment) (string, map[int64]int64) {
+		return comment.ID.Hex(), lo.MapKeys(comment.Reactions, func(_ int64, kind string) int64 {
+			k, _ := strconv.ParseInt(kind, 10, 64)
+			return k
+		})
+	})
+	mine := lo.SliceToMap(reactions, func(reaction *reactionMapper.Reaction) (string, int64) {
+		return reaction.CommentId, reaction.Kind
+	})
+	return lo.Map(commentIds, func(id string, _ int) *CommentReactions {
+		return &CommentReactions{CommentId: id, Counts: counts[id], Mine: mine[id]}
+	}), nil
+}
+
+// updateReaction 在事务中执行表态变更，并按变更前后的表态类型同步评论上的表态数量
+// 同一评论的并发表态会产生写冲突，事务会被整体重试，fn 每次都需要重新读取当前表态
+func (s *CommentService) updateReaction(ctx context.Context, commentId string, fn func(sessionContext mongo.SessionContext) (old, kind int64, err error)) (err error) {
+	if err = withTransaction(ctx, s.CommentMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+		var (
+			err1      error
+			old, kind int64
+		)
+		if old, kind, err1 = fn(sessionContext); err1 != nil {
+			log.CtxError(sessionContext, "修改表态 产生错误[%v]\n", err1)
+			return err1
+		}
+		if old != kind {
+			deltas := make(map[int64]int64, 2)
+			if old != 0 {
+				deltas[old] = consts.Decrement
+			}
+			if kind != 0 {
+				deltas[kind] = consts.Increment
+			}
+			if err1 = s.CommentMongoMapper.IncrReactions(sessionContext, commentId, deltas); err1 != nil {
+				log.CtxError(sessionContext, "更新表态数 产生错误[%v]\n", err1)
+				return err1
+			}
+		}
+		return nil
+	}); err != nil {
+		log.CtxError(ctx, "修改表态 失败[%v]\n", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	subjectMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/subject"
+	"github.com/google/wire"
+	"github.com/samber/lo"
+)
+
+const defaultReconcileBatchSize = 100
+
+type IReconcileService interface {
+	ReconcileSubject(ctx context.Context, subjectId string, repair bool) (report *ReconcileReport, err error)
+	ReconcileAll(ctx context.Context, batchSize int64, repair bool) (report *ReconcileReport, err error)
+	BackfillHeat(ctx context.Context) (updated int64, err error)
+}
+
+type ReconcileService struct {
+	CommentMongoMapper commentMapper.IMongoMapper
+	SubjectMongoMapper subjectMapper.IMongoMapper
+}
+
+var ReconcileSet = wire.NewSet(
+	wire.Struct(new(ReconcileService), "*"),
+	wire.Bind(new(IReconcileService), new(*ReconcileService)),
+)
+
+// CountDrift 记录一个计数字段的存储值与实际值的偏差，CommentId 为空时表示评论区自身的计数
+type CountDrift struct {
+	SubjectId string `json:"subjectId"`
+	CommentId string `json:"commentId,omitempty"`
+	Field     string `json:"field"`
+	Stored    int64  `json:"stored"`
+	Actual    int64  `json:"actual"`
+}
+
+type ReconcileReport struct {
+	Subjects int64         `json:"subjects"`
+	Drifts   []*CountDrift `json:"drifts"`
+	Repaired bool          `json:"repaired"`
+}
+
+func (s *ReconcileService) ReconcileSubject(ctx context.Context, subjectId string, repair bool) (report *ReconcileReport, err error) {
+	report = &ReconcileReport{Repaired: repair}
+	var subject *subjectMapper.Subject
+	if subject, err = s.SubjectMongoMapper.FindOne(ctx, subjectId); err != nil {
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return report, err
+	}
+	if report.Drifts, err = s.reconcile(ctx, subject, repair); err != nil {
+		return report, err
+	}
+	report.Subjects = 1
+	return report, nil
+}
+
+func (s *ReconcileService) ReconcileAll(ctx context.Context, batchSize int64, repair bool) (report *ReconcileReport, err error) {
+	report = &ReconcileReport{Repaired: repair}
+	if batchSize <= 0 {
+		batchSize = defaultReconcileBatchSize
+	}
+
+	var (
+		lastId   string
+		subjects []*subjectMapper.Subject
+		drifts   []*CountDrift
+	)
+	for {
+		if subjects, err = s.SubjectMongoMapper.FindBatch(ctx, lastId, batchSize); err != nil {
+			log.CtxError(ctx, "获取评论区列表 失败[%v]\n", err)
+			return report, err
+		}
+		for _, subject := range subjects {
+			if drifts, err = s.reconcile(ctx, subject, repair); err != nil {
+				return report, err
+			}
+			report.Drifts = append(report.Drifts, drifts...)
+			report.Subjects++
+		}
+		if int64(len(subjects)) < batchSize {
+			return report, nil
+		}
+		lastId = subjects[len(subjects)-1].ID.Hex()
+	}
+}
+
+// BackfillHeat 为上线热度排序之前创建的评论补齐热度，缺少热度的评论不会出现在按最热排序的列表中
+func (s *ReconcileService) BackfillHeat(ctx context.Context) (updated int64, err error) {
+	if updated, err = s.CommentMongoMapper.RefreshHeat(ctx, &commentMapper.FilterOptions{OnlyMissingHeat: true}); err != nil {
+		log.CtxError(ctx, "补齐评论热度 失败[%v]\n", err)
+		return 0, err
+	}
+	return updated, nil
+}
+
+// reconcile 根据评论集合重新计算评论区及其一级评论的计数，repair 为 true 时以差值增量修正偏差
+func (s *ReconcileService) reconcile(ctx context.Context, subject *subjectMapper.Subject, repair bool) ([]*CountDrift, error) {
+	var (
+		err       error
+		drifts    []*CountDrift
+		rootCount int64
+		allCount  int64
+		roots     []*commentMapper.Comment
+		replies   map[string]int64
+	)
+
+	subjectId := subject.ID.Hex()
+	if allCount, err = s.CommentMongoMapper.Count(ctx, &commentMapper.FilterOptions{OnlySubjectId: lo.ToPtr(subjectId), ExcludeStates: consts.UncountedStates}); err != nil {
+		log.CtxError(ctx, "统计评论数 失败[%v]\n", err)
+		return nil, err
+	}
+	if roots, err = s.CommentMongoMapper.FindAll(ctx, &commentMapper.FilterOptions{OnlySubjectId: lo.ToPtr(subjectId), OnlyRootId: lo.ToPtr(subjectId)}); err != nil {
+		log.CtxError(ctx, "获取一级评论 失败[%v]\n", err)
+		return nil, err
+	}
+	if replies, err = s.CommentMongoMapper.CountReplies(ctx, subjectId); err != nil {
+		log.CtxError(ctx, "统计回复数 失败[%v]\n", err)
+		return nil, err
+	}
+	// 已删除或未通过审核的一级评论不计入 rootCount，但其下仍可能有回复，需要一并校对 count
+	rootCount = int64(lo.CountBy(roots, func(root *commentMapper.Comment) bool {
+		return consts.IsCounted(root.State)
+	}))
+
+	storedRootCount, storedAllCount := lo.FromPtr(subject.RootCount), lo.FromPtr(subject.AllCount)
+	if storedRootCount != rootCount {
+		drifts = append(drifts, &CountDrift{SubjectId: subjectId, Field: consts.RootCount, Stored: storedRootCount, Actual: rootCount})
+	}
+	if storedAllCount != allCount {
+		drifts = append(drifts, &CountDrift{SubjectId: subjectId, Field: consts.AllCount, Stored: storedAllCount, Actual: allCount})
+	}
+	if repair && (storedRootCount != rootCount || storedAllCount != allCount) {
+		if err = s.SubjectMongoMapper.IncrCount(ctx, subjectId, rootCount-storedRootCount, allCount-storedAllCount); err != nil {
+			log.CtxError(ctx, "修正评论区计数 失败[%v]\n", err)
+			return drifts, err
+		}
+	}
+
+	for _, root := range roots {
+		commentId := root.ID.Hex()
+		stored, actual := lo.FromPtr(root.Count), replies[commentId]
+		if stored == actual {
+			continue
+		}
+		drifts = append(drifts, &CountDrift{SubjectId: subjectId, CommentId: commentId, Field: consts.Count, Stored: stored, Actual: actual})
+		if repair {
+			if err = s.CommentMongoMapper.IncrCount(ctx, commentId, actual-stored); err != nil {
+				log.CtxError(ctx, "修正评论计数 失败[%v]\n", err)
+				return drifts, err
+			}
+		}
+	}
+	return drifts, nil
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/event"
+	changeMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/change"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	outboxMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/outbox"
+	reactionMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/reaction"
+	recycleMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/recycle"
+	revisionMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/revision"
+	subjectMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/subject"
+	"github.com/google/wire"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+type IRecycleService interface {
+	RestoreComment(ctx context.Context, commentId string) (err error)
+	Purge(ctx context.Context) (purged int64, err error)
+	RunPurge(ctx context.Context)
+}
+
+type RecycleService struct {
+	Config              *config.Config
+	CommentMongoMapper  commentMapper.IMongoMapper
+	CommentEsMapper     commentMapper.IEsMapper
+	SubjectMongoMapper  subjectMapper.IMongoMapper
+	RecycleMongoMapper  recycleMapper.IMongoMapper
+	RevisionMongoMapper revisionMapper.IMongoMapper
+	ReactionMongoMapper reactionMapper.IMongoMapper
+	OutboxMongoMapper   outboxMapper.IMongoMapper
+	ChangeMongoMapper   changeMapper.IMongoMapper
+	Bus                 *event.Bus
+}
+
+var RecycleSet = wire.NewSet(
+	wire.Struct(new(RecycleService), "*"),
+	wire.Bind(new(IRecycleService), new(*RecycleService)),
+)
+
+// RestoreComment 在保留期内恢复被删除的评论及随其一起删除的回复，并修正计数
+// 以墓碑形式删除的评论恢复删除前的内容，其回复始终保留在评论树中，无需恢复
+func (s *RecycleService) RestoreComment(ctx context.Context, commentId string) (err error) {
+	var (
+		entries []*recycleMapper.Recycle
+		target  *recycleMapper.Recycle
+	)
+	if s.Config.Recycle.Retention <= 0 {
+		return consts.ErrComponentNotStarted
+	}
+	if entries, err = s.RecycleMongoMapper.FindByBatchId(ctx, commentId); err != nil {
+		log.CtxError(ctx, "获取回收站评论 失败[%v]\n", err)
+		return err
+	}
+	if target, _ = lo.Find(entries, func(entry *recycleMapper.Recycle) bool {
+		return entry.Comment.ID.Hex() == commentId
+	}); target == nil || time.Since(target.DeleteAt) > s.Config.Recycle.Retention {
+		return consts.ErrNotFound
+	}
+
+	data := target.Comment
+	if _, err = s.SubjectMongoMapper.FindOne(ctx, data.SubjectId); err != nil {
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return err
+	}
+	// 回复只能恢复到仍然存在的一级评论下
+	if data.RootId != data.SubjectId {
+		if _, err = s.CommentMongoMapper.FindOne(ctx, data.RootId); err != nil {
+			log.CtxError(ctx, "获取一级评论 失败[%v]\n", err)
+			return consts.ErrIllegalOperation
+		}
+	}
+
+	// 墓碑期间回复数可能已经变化，以评论集合中的当前值为准
+	if target.Tombstone {
+		var current *commentMapper.Comment
+		if current, err = s.CommentMongoMapper.FindOne(ctx, commentId); err != nil {
+			log.CtxError(ctx, "获取评论详情 失败[%v]\n", err)
+			return err
+		}
+		data.Count = current.Count
+	}
+
+	comments := lo.Map(entries, func(entry *recycleMapper.Recycle, _ int) *commentMapper.Comment {
+		return entry.Comment
+	})
+	ids := lo.Map(comments, func(comment *commentMapper.Comment, _ int) string {
+		return comment.ID.Hex()
+	})
+	delta, allDelta := restoreCounts(data, comments, target.Tombstone)
+
+	var changes []*changeMapper.Change
+	if err = withTransaction(ctx, s.CommentMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+		var err1 error
+		if target.Tombstone {
+			err1 = s.CommentMongoMapper.RestoreTombstone(sessionContext, data)
+		} else {
+			err1 = s.CommentMongoMapper.InsertMany(sessionContext, comments)
+		}
+		if err1 != nil {
+			log.CtxError(sessionContext, "恢复评论 产生错误[%v]\n", err1)
+			return err1
+		}
+		if _, err1 = s.RecycleMongoMapper.DeleteMany(sessionContext, ids); err1 != nil {
+			log.CtxError(sessionContext, "移出回收站 产生错误[%v]\n", err1)
+			return err1
+		}
+		if data.RootId == data.SubjectId {
+			err1 = s.SubjectMongoMapper.IncrCount(sessionContext, data.SubjectId, delta, allDelta)
+		} else if err1 = s.CommentMongoMapper.IncrCount(sessionContext, data.RootId, delta); err1 == nil {
+			err1 = s.SubjectMongoMapper.IncrCount(sessionContext, data.SubjectId, consts.InitNumber, allDelta)
+		}
+		if err1 != nil {
+			log.CtxError(sessionContext, "更新评论数 产生错误[%v]\n", err1)
+			return err1
+		}
+		if changes, err1 = recordChanges(sessionContext, s.SubjectMongoMapper, s.ChangeMongoMapper, data.SubjectId, changeMapper.RestoreOp, comments...); err1 != nil {
+			log.CtxError(sessionContext, "记录评论变更 产生错误[%v]\n", err1)
+			return err1
+		}
+		return nil
+	}); err != nil {
+		log.CtxError(ctx, "恢复评论 失败[%v]\n", err)
+		return err
+	}
+	publishChanges(s.Bus, changes, comments...)
+	// 恢复的评论重新写入搜索索引，失败不影响恢复结果
+	for _, comment := range comments {
+		if err = s.CommentEsMapper.Index(ctx, comment); err != nil {
+			log.CtxError(ctx, "同步评论索引 失败[%v]\n", err)
+		}
+	}
+	return nil
+}
+
+// restoreCounts 返回恢复一批评论时的计数调整量，参数含义与 incrCount 相同
+// 以快照中的状态为准，只恢复计入计数的评论的计数，墓碑等状态在变更时已经扣减过；
+// 删除前已是墓碑的回复以原内容一同恢复，因此重新插入的一级评论的回复数改为随其恢复的回复数
+func restoreCounts(data *commentMapper.Comment, comments []*commentMapper.Comment, tombstone bool) (delta, allDelta int64) {
+	if !tombstone && data.RootId == data.SubjectId {
+		data.Count = lo.ToPtr(countedNumber(lo.Without(comments, data)))
+	}
+	alive := countedNumber(comments)
+	if data.RootId == data.SubjectId {
+		return countDelta(data.State, consts.Increment), alive
+	}
+	return alive, alive
+}
+
+// Purge 彻底删除超过保留期的评论：在同一事务中清理历史版本与表态、写入删除关联关系的消息并移出回收站
+func (s *RecycleService) Purge(ctx context.Context) (purged int64, err error) {
+	// 保留时长不为正数时所有评论都会被立即清理，拒绝执行
+	if s.Config.Recycle.Retention <= 0 || s.Config.Recycle.BatchSize <= 0 {
+		return 0, consts.ErrComponentNotStarted
+	}
+	var entries []*recycleMapper.Recycle
+	before := time.Now().Add(-s.Config.Recycle.Retention)
+	for {
+		if entries, err = s.RecycleMongoMapper.FindExpired(ctx, before, s.Config.Recycle.BatchSize); err != nil {
+			log.CtxError(ctx, "获取过期评论 失败[%v]\n", err)
+			return purged, err
+		}
+		if len(entries) == 0 {
+			return purged, nil
+		}
+
+		ids := lo.Map(entries, func(entry *recycleMapper.Recycle, _ int) string {
+			return entry.ID.Hex()
+		})
+		// 关联关系消息与清理在同一事务中写入发件箱，由投递任务异步发送
+		var n int64
+		if err = withTransaction(ctx, s.CommentMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+			var err1 error
+			if _, err1 = s.RevisionMongoMapper.DeleteByCommentIds(sessionContext, ids); err1 != nil {
+				log.CtxError(sessionContext, "删除评论历史版本 产生错误[%v]\n", err1)
+				return err1
+			}
+			if _, err1 = s.ReactionMongoMapper.DeleteByCommentIds(sessionContext, ids); err1 != nil {
+				log.CtxError(sessionContext, "删除评论表态 产生错误[%v]\n", err1)
+				return err1
+			}
+			if err1 = s.OutboxMongoMapper.InsertMany(sessionContext, lo.Map(entries, func(entry *recycleMapper.Recycle, _ int) *outboxMapper.Outbox {
+				return newDeleteRelationOutbox(entry.Comment.Type, entry.ID.Hex())
+			})); err1 != nil {
+				log.CtxError(sessionContext, "写入删除评论关联消息 产生错误[%v]\n", err1)
+				return err1
+			}
+			if n, err1 = s.RecycleMongoMapper.DeleteMany(sessionContext, ids); err1 != nil {
+				log.CtxError(sessionContext, "清理回收站 产生错误[%v]\n", err1)
+				return err1
+			}
+			return nil
+		}); err != nil {
+			log.CtxError(ctx, "清理回收站 失败[%v]\n", err)
+			return purged, err
+		}
+		purged += n
+	}
+}
+
+// RunPurge 按配置的间隔定期清理回收站，直到 ctx 结束
+func (s *RecycleService) RunPurge(ctx context.Context) {
+	if s.Config.Recycle.PurgeInterval <= 0 || s.Config.Recycle.Retention <= 0 {
+		log.CtxError(ctx, "回收站配置无效，不启动清理: 间隔[%v] 保留时长[%v]\n", s.Config.Recycle.PurgeInterval, s.Config.Recycle.Retention)
+		return
+	}
+	ticker := time.NewTicker(s.Config.Recycle.PurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if purged, err := s.Purge(ctx); err != nil {
+				log.CtxError(ctx, "清理回收站 失败[%v]\n", err)
+			} else if purged > 0 {
+				log.CtxInfo(ctx, "清理回收站: 删除评论[%d]\n", purged)
+			}
+		}
+	}
+}
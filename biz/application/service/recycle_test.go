@@ -0,0 +1,133 @@
+package service
+
+import (
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+// counters 评论区与一级评论的计数，按 incrCount 的语义调整
+type counters struct {
+	rootCount int64
+	allCount  int64
+	count     map[string]int64
+}
+
+func (c *counters) incr(subjectId, rootId string, delta, allDelta int64) {
+	if rootId == subjectId {
+		c.rootCount += delta
+	} else {
+		c.count[rootId] += delta
+	}
+	c.allCount += allDelta
+}
+
+// tally 按评论集合重新计算计数，与校对计数的口径一致
+func tally(subjectId string, comments []*commentMapper.Comment) *counters {
+	c := &counters{count: make(map[string]int64)}
+	for _, comment := range comments {
+		if comment.RootId == subjectId {
+			c.count[comment.ID.Hex()] += 0
+		}
+		if !consts.IsCounted(comment.State) {
+			continue
+		}
+		c.allCount++
+		if comment.RootId == subjectId {
+			c.rootCount++
+		} else {
+			c.count[comment.RootId]++
+		}
+	}
+	return c
+}
+
+func checkCounters(t *testing.T, stage string, got, want *counters) {
+	t.Helper()
+	if got.rootCount != want.rootCount || got.allCount != want.allCount {
+		t.Errorf("%s: rootCount = %d, allCount = %d, want %d, %d", stage, got.rootCount, got.allCount, want.rootCount, want.allCount)
+	}
+	for rootId, count := range want.count {
+		if got.count[rootId] != count {
+			t.Errorf("%s: count[%s] = %d, want %d", stage, rootId, got.count[rootId], count)
+		}
+	}
+}
+
+// 删除后再恢复，每一步的计数都应与按评论集合重新计算的结果一致
+func TestDeleteRestoreCounts(t *testing.T) {
+	subjectId := primitive.NewObjectID().Hex()
+	// 评论名到其一级评论名，一级评论为空
+	roots := map[string]string{"A": "", "B": "", "a1": "A", "a2": "A", "a3": "A", "b1": "B"}
+	tests := []struct {
+		name   string
+		target string
+		// states 删除前评论集合中的状态，未列出的为正常
+		states map[string]int64
+		// snapshots 回收站快照中与删除前不同的状态
+		snapshots map[string]int64
+	}{
+		{name: "一级评论连同回复", target: "A"},
+		{name: "带有待审核回复的一级评论", target: "A", states: map[string]int64{"a3": consts.PendingState}},
+		{name: "待审核的一级评论", target: "A", states: map[string]int64{"A": consts.PendingState}},
+		{name: "回复", target: "a1"},
+		{name: "隐藏的回复", target: "a1", states: map[string]int64{"a1": consts.HiddenState}},
+		{
+			name:      "删除前已是墓碑的回复以原内容恢复",
+			target:    "A",
+			states:    map[string]int64{"a2": consts.DeletedState, "a3": consts.PendingState},
+			snapshots: map[string]int64{"a2": consts.NormalState},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := lo.MapValues(roots, func(_ string, _ string) primitive.ObjectID { return primitive.NewObjectID() })
+			names := lo.Invert(ids)
+			newComment := func(name string, state int64) *commentMapper.Comment {
+				rootId := subjectId
+				if root := roots[name]; root != "" {
+					rootId = ids[root].Hex()
+				}
+				return &commentMapper.Comment{ID: ids[name], SubjectId: subjectId, RootId: rootId, FatherId: rootId, State: state}
+			}
+			var live []*commentMapper.Comment
+			for name := range roots {
+				state, ok := tt.states[name]
+				if !ok {
+					state = consts.NormalState
+				}
+				live = append(live, newComment(name, state))
+			}
+			c := tally(subjectId, live)
+
+			// 删除：一级评论连同其回复一起删除
+			target, _ := lo.Find(live, func(comment *commentMapper.Comment) bool { return comment.ID == ids[tt.target] })
+			deleted := lo.Filter(live, func(comment *commentMapper.Comment, _ int) bool {
+				return comment == target || target.RootId == subjectId && comment.RootId == target.ID.Hex()
+			})
+			delta, allDelta := deleteCountDelta(target, lo.Without(deleted, target))
+			c.incr(subjectId, target.RootId, delta, allDelta)
+			remaining := lo.Without(live, deleted...)
+			checkCounters(t, "删除后", c, tally(subjectId, remaining))
+
+			// 恢复：以回收站中的快照重新插入
+			snapshots := lo.Map(deleted, func(comment *commentMapper.Comment, _ int) *commentMapper.Comment {
+				snapshot := *comment
+				if state, ok := tt.snapshots[names[comment.ID]]; ok {
+					snapshot.State = state
+				}
+				return &snapshot
+			})
+			data, _ := lo.Find(snapshots, func(comment *commentMapper.Comment) bool { return comment.ID == target.ID })
+			delta, allDelta = restoreCounts(data, snapshots, false)
+			c.incr(subjectId, data.RootId, delta, allDelta)
+			// 重新插入的一级评论带有 restoreCounts 修正后的回复数
+			if data.RootId == subjectId {
+				c.count[data.ID.Hex()] = lo.FromPtr(data.Count)
+			}
+			checkCounters(t, "恢复后", c, tally(subjectId, append(remaining, snapshots...)))
+		})
+	}
+}
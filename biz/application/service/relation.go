@@ -11,6 +11,7 @@ import (
 	"github.com/CloudStriver/platform/biz/infrastructure/config"
 	"github.com/CloudStriver/platform/biz/infrastructure/consts"
 	"github.com/CloudStriver/platform/biz/infrastructure/convertor"
+	"github.com/CloudStriver/platform/biz/infrastructure/idempotent"
 	relationmapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/relation"
 	"github.com/CloudStriver/platform/biz/infrastructure/sort"
 	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform"
@@ -19,6 +20,7 @@ import (
 	"github.com/zeromicro/go-zero/core/stores/redis"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"strconv"
 )
 
 type RelationService interface {
@@ -40,6 +42,7 @@ var RelationSet = wire.NewSet(
 type RelationServiceImpl struct {
 	Config              *config.Config
 	Redis               *redis.Redis
+	Idempotent          *idempotent.Store
 	RelationModel       relationmapper.RelationNeo4jMapper
 	RelationMongoMapper relationmapper.IMongoMapper
 }
@@ -225,8 +228,22 @@ func (s *RelationServiceImpl) DeleteRelation(ctx context.Context, req *platform.
 	return resp, nil
 }
 
+// CreateRelation 创建关系，携带幂等键的重试请求直接返回首次请求的结果
 func (s *RelationServiceImpl) CreateRelation(ctx context.Context, req *platform.CreateRelationReq) (resp *platform.CreateRelationResp, err error) {
 	resp = new(platform.CreateRelationResp)
+	var ok string
+	if ok, err = s.Idempotent.Do(ctx, fmt.Sprintf("relation:%d:%s", req.FromType, req.FromId), idempotent.KeyFromContext(ctx), func() (string, error) {
+		res, err := s.createRelation(ctx, req)
+		return strconv.FormatBool(res.Ok), err
+	}); err != nil {
+		return resp, err
+	}
+	resp.Ok, _ = strconv.ParseBool(ok)
+	return resp, nil
+}
+
+func (s *RelationServiceImpl) createRelation(ctx context.Context, req *platform.CreateRelationReq) (resp *platform.CreateRelationResp, err error) {
+	resp = new(platform.CreateRelationResp)
 
 	var res *platform.GetRelationResp
 	if res, err = s.GetRelation(ctx, &platform.GetRelationReq{
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/pagination/mongop"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/convertor"
+	changeMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/change"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	moderationMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/moderation"
+	revisionMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/revision"
+	subjectMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/subject"
+	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/basic"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"time"
+)
+
+// CommentRevision 评论的一个历史版本，Content 为该次编辑前的内容
+type CommentRevision struct {
+	RevisionId string `json:"revisionId"`
+	CommentId  string `json:"commentId"`
+	UserId     string `json:"userId"`
+	Content    string `json:"content"`
+	CreateTime int64  `json:"createTime"`
+}
+
+type GetCommentRevisionsResp struct {
+	Revisions []*CommentRevision `json:"revisions"`
+	Total     int64              `json:"total"`
+	Token     string             `json:"token"`
+}
+
+// EditComment 修改评论内容，并在同一事务中记录修改前的版本，只有评论作者可以编辑
+// 新内容与发表评论一样经过敏感词检查，按策略转为待审核时同步调整计数并写入审核记录
+func (s *CommentService) EditComment(ctx context.Context, commentId, userId, content string) (resp *CommentDetail, err error) {
+	if userId == "" {
+		return nil, consts.ErrIllegalOperation
+	}
+	var data *commentMapper.Comment
+	if data, err = s.CommentMongoMapper.FindOne(ctx, commentId); err != nil {
+		log.CtxError(ctx, "获取评论详情 失败[%v]\n", err)
+		return nil, err
+	}
+	if data.UserId != userId || data.State == consts.DeletedState || content == "" {
+		return nil, consts.ErrIllegalOperation
+	}
+	if data.Content == content {
+		return toCommentDetail(data), nil
+	}
+	var subject *subjectMapper.Subject
+	if subject, err = s.checkWritable(ctx, data.SubjectId); err != nil {
+		return nil, err
+	}
+	var (
+		state int64
+		words []string
+	)
+	if content, state, words, err = s.checkSensitive(ctx, subject, userId, content, data.State); err != nil {
+		return nil, err
+	}
+	mentions, editAt := parseMentions(content), time.Now()
+
+	var changes []*changeMapper.Change
+	if err = withTransaction(ctx, s.CommentMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+		var err1 error
+		if _, err1 = s.RevisionMongoMapper.Insert(sessionContext, &revisionMapper.Revision{
+			CommentId: commentId,
+			UserId:    userId,
+			Content:   data.Content,
+		}); err1 != nil {
+			log.CtxError(sessionContext, "记录评论版本 产生错误[%v]\n", err1)
+			return err1
+		}
+		if err1 = s.CommentMongoMapper.UpdateContent(sessionContext, commentId, content, mentions, words, editAt); err1 != nil {
+			log.CtxError(sessionContext, "编辑评论 产生错误[%v]\n", err1)
+			return err1
+		}
+		if state != data.State {
+			if err1 = s.CommentMongoMapper.UpdateState(sessionContext, commentId, data.State, state); err1 != nil {
+				log.CtxError(sessionContext, "变更评论状态 产生错误[%v]\n", err1)
+				return err1
+			}
+			if delta := countDelta(state, consts.Increment) - countDelta(data.State, consts.Increment); delta != 0 {
+				if err1 = s.incrCount(sessionContext, data.SubjectId, data.RootId, delta, delta); err1 != nil {
+					log.CtxError(sessionContext, "更新评论数 产生错误[%v]\n", err1)
+					return err1
+				}
+			}
+		}
+		if len(words) > 0 {
+			if _, err1 = s.ModerationMongoMapper.Insert(sessionContext, &moderationMapper.Moderation{
+				CommentId: commentId,
+				SubjectId: data.SubjectId,
+				FromState: data.State,
+				ToState:   state,
+				Reason:    "编辑后命中敏感词: " + strings.Join(words, ","),
+			}); err1 != nil {
+				log.CtxError(sessionContext, "记录审核操作 产生错误[%v]\n", err1)
+				return err1
+			}
+		}
+		if changes, err1 = recordChanges(sessionContext, s.SubjectMongoMapper, s.ChangeMongoMapper, data.SubjectId, changeMapper.EditOp, data); err1 != nil {
+			log.CtxError(sessionContext, "记录评论变更 产生错误[%v]\n", err1)
+			return err1
+		}
+		return nil
+	}); err != nil {
+		log.CtxError(ctx, "编辑评论 失败[%v]\n", err)
+		return nil, err
+	}
+	// 只通知编辑后新增的被提及用户
+	notified := lo.Map(data.Mentions, func(mention commentMapper.Mention, _ int) string {
+		return mention.UserId
+	})
+	data.Content, data.Mentions, data.State, data.Sensitive, data.EditAt = content, mentions, state, words, editAt
+	publishChanges(s.Bus, changes, data)
+	s.indexComment(ctx, data)
+	s.pushMentions(ctx, data, notified)
+	return nil, nil
+}
+
+func (s *CommentService) GetCommentRevisions(ctx context.Context, commentId string, pagination *basic.PaginationOptions) (resp *GetCommentRevisionsResp, err error) {
+	resp = new(GetCommentRevisionsResp)
+	var revisions []*revisionMapper.Revision
+	p := convertor.ParsePagination(pagination)
+	if revisions, resp.Total, err = s.RevisionMongoMapper.FindManyAndCount(ctx, commentId, p, mongop.IdCursorType); err != nil {
+		log.CtxError(ctx, "获取评论历史版本 失败[%v]\n", err)
+		return resp, err
+	}
+	if p.LastToken != nil {
+		resp.Token = *p.LastToken
+	}
+	resp.Revisions = lo.Map(revisions, func(item *revisionMapper.Revision, _ int) *CommentRevision {
+		return &CommentRevision{
+			RevisionId: item.ID.Hex(),
+			CommentId:  item.CommentId,
+			UserId:     item.UserId,
+			Content:    item.Content,
+			CreateTime: item.CreateAt.UnixMilli(),
+		}
+	})
+	return resp, nil
+}
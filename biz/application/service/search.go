@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/pagination/esp"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/convertor"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/basic"
+	"github.com/samber/lo"
+)
+
+// CommentSearchResult 一条搜索结果，Highlights 为正文中命中关键词的片段，关键词以 <em> 标记
+type CommentSearchResult struct {
+	Comment    *CommentDetail `json:"comment"`
+	Highlights []string       `json:"highlights"`
+}
+
+type SearchCommentsResp struct {
+	Results []*CommentSearchResult `json:"results"`
+	Total   int64                  `json:"total"`
+	Token   string                 `json:"token"`
+}
+
+// SearchComments 按关键词全文搜索评论，结果按相关度排序，正文以 mongo 中的最新数据为准
+func (s *CommentService) SearchComments(ctx context.Context, keyword string, fopts *commentMapper.EsFilterOptions, pagination *basic.PaginationOptions) (resp *SearchCommentsResp, err error) {
+	resp = new(SearchCommentsResp)
+	var (
+		hits  []*commentMapper.SearchHit
+		total int64
+	)
+	p := convertor.ParsePagination(pagination)
+	if hits, total, err = s.CommentEsMapper.Search(ctx, convertor.ConvertCommentContentSearchQuery(keyword), fopts, p, esp.ScoreCursorType); err != nil {
+		log.CtxError(ctx, "搜索评论 失败[%v]\n", err)
+		return resp, consts.ErrEsMapper
+	}
+	if p.LastToken != nil {
+		resp.Token = *p.LastToken
+	}
+	resp.Total = total
+	if len(hits) == 0 {
+		return resp, nil
+	}
+
+	// 索引同步存在延迟，以 mongo 中的状态为准过滤，未指定状态时只返回对外可见的评论
+	excludeStates := consts.UncountedStates
+	if fopts != nil && len(fopts.OnlyStates) > 0 {
+		excludeStates = []int64{consts.DeletedState}
+	}
+	var comments []*commentMapper.Comment
+	if comments, err = s.CommentMongoMapper.FindAll(ctx, &commentMapper.FilterOptions{
+		OnlyCommentIds: lo.Map(hits, func(hit *commentMapper.SearchHit, _ int) string { return hit.ID.Hex() }),
+		ExcludeStates:  excludeStates,
+	}); err != nil {
+		log.CtxError(ctx, "获取评论 失败[%v]\n", err)
+		return resp, err
+	}
+	byId := lo.KeyBy(comments, func(comment *commentMapper.Comment) string {
+		return comment.ID.Hex()
+	})
+	resp.Results = lo.FilterMap(hits, func(hit *commentMapper.SearchHit, _ int) (*CommentSearchResult, bool) {
+		data, ok := byId[hit.ID.Hex()]
+		if !ok {
+			return nil, false
+		}
+		return &CommentSearchResult{Comment: toCommentDetail(data), Highlights: hit.Highlights}, true
+	})
+	return resp, nil
+}
+
+// indexComment 同步评论到搜索索引，失败只记录日志，不影响主流程
+func (s *CommentService) indexComment(ctx context.Context, data *commentMapper.Comment) {
+	if err := s.CommentEsMapper.Index(ctx, data); err != nil {
+		log.CtxError(ctx, "同步评论索引 失败[%v]\n", err)
+	}
+}
+
+// unindexComments 从搜索索引中移除评论，失败只记录日志，不影响主流程
+func (s *CommentService) unindexComments(ctx context.Context, ids []string) {
+	if err := s.CommentEsMapper.Delete(ctx, ids); err != nil {
+		log.CtxError(ctx, "删除评论索引 失败[%v]\n", err)
+	}
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/convertor"
+	"github.com/CloudStriver/platform/biz/infrastructure/event"
+	changeMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/change"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	subjectMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/subject"
+	"github.com/google/wire"
+	"github.com/samber/lo"
+	"time"
+)
+
+type IStreamService interface {
+	Stream(ctx context.Context, subjectId string, seq int64) (events <-chan *event.Event, err error)
+	RunPoll(ctx context.Context)
+}
+
+type StreamService struct {
+	Config             *config.Config
+	Bus                *event.Bus
+	SubjectMongoMapper subjectMapper.IMongoMapper
+	CommentMongoMapper commentMapper.IMongoMapper
+	ChangeMongoMapper  changeMapper.IMongoMapper
+}
+
+var StreamSet = wire.NewSet(
+	wire.Struct(new(StreamService), "*"),
+	wire.Bind(new(IStreamService), new(*StreamService)),
+)
+
+// Stream 订阅评论区的评论变更，返回的事件按序号连续递增，ctx 结束后关闭
+// seq 大于 0 时先从变更记录补齐 seq 之后的变更，否则只推送订阅之后的变更
+func (s *StreamService) Stream(ctx context.Context, subjectId string, seq int64) (events <-chan *event.Event, err error) {
+	var subject *subjectMapper.Subject
+	if subject, err = s.SubjectMongoMapper.FindOne(ctx, subjectId); err != nil {
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return nil, err
+	}
+	if seq <= 0 {
+		seq = subject.Seq
+	}
+
+	// 先订阅再补齐，补齐期间发布的事件留在订阅缓冲中，按序号去重
+	sub := s.Bus.Subscribe(subjectId)
+	out := make(chan *event.Event)
+	go func() {
+		defer close(out)
+		defer func() { s.Bus.Unsubscribe(sub) }()
+		last := seq
+		if !s.replay(ctx, subjectId, &last, out) {
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-sub.C:
+				switch {
+				case !ok:
+					// 消费过慢被移出订阅，重新订阅后从变更记录补齐
+					sub = s.Bus.Subscribe(subjectId)
+					if !s.replay(ctx, subjectId, &last, out) {
+						return
+					}
+				case ev.Seq <= last:
+				case ev.Seq > last+1:
+					// 中间的变更来自其他实例或发布顺序与提交顺序不一致，从变更记录补齐
+					if !s.replay(ctx, subjectId, &last, out) {
+						return
+					}
+				default:
+					if !send(ctx, out, ev) {
+						return
+					}
+					last = ev.Seq
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// replay 从变更记录中按序发送 last 之后的全部变更，评论内容为当前的最新状态，ctx 结束或读取失败时返回 false
+func (s *StreamService) replay(ctx context.Context, subjectId string, last *int64, out chan<- *event.Event) bool {
+	batch := s.Config.Stream.ReplayBatch
+	for {
+		events, err := s.loadEvents(ctx, subjectId, *last, batch)
+		if err != nil {
+			return false
+		}
+		for _, ev := range events {
+			if !send(ctx, out, ev) {
+				return false
+			}
+			*last = ev.Seq
+		}
+		if int64(len(events)) < batch {
+			return true
+		}
+	}
+}
+
+// loadEvents 从变更记录中按序读取 last 之后的至多 limit 条变更，评论内容为当前的最新状态
+func (s *StreamService) loadEvents(ctx context.Context, subjectId string, last, limit int64) ([]*event.Event, error) {
+	changes, err := s.ChangeMongoMapper.FindSince(ctx, subjectId, last, time.Time{}, limit)
+	if err != nil {
+		log.CtxError(ctx, "获取评论变更 失败[%v]\n", err)
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	var comments []*commentMapper.Comment
+	if comments, err = s.CommentMongoMapper.FindAll(ctx, &commentMapper.FilterOptions{
+		OnlyCommentIds: lo.Uniq(lo.Map(changes, func(change *changeMapper.Change, _ int) string { return change.CommentId })),
+	}); err != nil {
+		log.CtxError(ctx, "获取评论 失败[%v]\n", err)
+		return nil, err
+	}
+	return newCommentEvents(changes, comments), nil
+}
+
+// RunPoll 按配置的间隔为本实例中有订阅的评论区轮询变更记录，把其他实例写入的变更发布到事件总线，直到 ctx 结束
+// 每个评论区每次只查询一次，与订阅数无关；本实例写入的变更会被再次发布，由订阅方按序号去重
+func (s *StreamService) RunPoll(ctx context.Context) {
+	interval := s.Config.Stream.PollInterval
+	if interval <= 0 || s.Config.Stream.ReplayBatch <= 0 {
+		log.CtxError(ctx, "评论变更推送配置无效，不轮询变更记录: 间隔[%v] 每批[%d]\n", interval, s.Config.Stream.ReplayBatch)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	watermarks := make(map[string]int64)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.poll(ctx, watermarks)
+		}
+	}
+}
+
+// poll 发布每个有订阅的评论区在水位之后的变更；新出现的评论区从当前序号开始，已没有订阅的评论区移出水位
+func (s *StreamService) poll(ctx context.Context, watermarks map[string]int64) {
+	active := s.Bus.Subjects()
+	for subjectId := range watermarks {
+		if !lo.Contains(active, subjectId) {
+			delete(watermarks, subjectId)
+		}
+	}
+	for _, subjectId := range active {
+		last, ok := watermarks[subjectId]
+		if !ok {
+			subject, err := s.SubjectMongoMapper.FindOne(ctx, subjectId)
+			if err != nil {
+				log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+				continue
+			}
+			watermarks[subjectId] = subject.Seq
+			continue
+		}
+		events, err := s.loadEvents(ctx, subjectId, last, s.Config.Stream.ReplayBatch)
+		if err != nil || len(events) == 0 {
+			continue
+		}
+		s.Bus.Publish(events...)
+		watermarks[subjectId] = events[len(events)-1].Seq
+	}
+}
+
+func send(ctx context.Context, out chan<- *event.Event, ev *event.Event) bool {
+	select {
+	case out <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// publishChanges 在事务提交后发布变更事件，comments 为变更后的评论，未传入的评论视为已删除
+func publishChanges(bus *event.Bus, changes []*changeMapper.Change, comments ...*commentMapper.Comment) {
+	bus.Publish(newCommentEvents(changes, comments)...)
+}
+
+func newCommentEvents(changes []*changeMapper.Change, comments []*commentMapper.Comment) []*event.Event {
+	byId := lo.KeyBy(comments, func(comment *commentMapper.Comment) string {
+		return comment.ID.Hex()
+	})
+	return lo.Map(changes, func(change *changeMapper.Change, _ int) *event.Event {
+		ev := &event.Event{
+			SubjectId: change.SubjectId,
+			Seq:       change.Seq,
+			Op:        change.Op,
+			CommentId: change.CommentId,
+			RootId:    change.RootId,
+		}
+		if data, ok := byId[change.CommentId]; ok && !lo.Contains(consts.InvisibleStates, data.State) {
+			ev.Comment = convertor.CommentMapperToComment(data)
+		}
+		return ev
+	})
+}
@@ -2,29 +2,72 @@ package service
 
 import (
 	"context"
-	"github.com/CloudStriver/cloudmind-mq/app/util/message"
-	"github.com/CloudStriver/go-pkg/utils/pconvertor"
+	"errors"
 	"github.com/CloudStriver/go-pkg/utils/util/log"
-	"github.com/CloudStriver/platform/biz/infrastructure/kq"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	changeMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/change"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	moderationMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/moderation"
+	outboxMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/outbox"
+	reactionMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/reaction"
+	recycleMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/recycle"
+	revisionMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/revision"
 	subjectMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/subject"
 	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform"
-	"github.com/bytedance/sonic"
 	"github.com/google/wire"
 	"github.com/samber/lo"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
 )
 
 type ISubjectService interface {
-	UpdateCount(ctx context.Context, subjectId string, rootCount, allCount int64)
 	GetCommentSubject(ctx context.Context, req *platform.GetCommentSubjectReq) (resp *platform.GetCommentSubjectResp, err error)
 	CreateCommentSubject(ctx context.Context, req *platform.CreateCommentSubjectReq) (resp *platform.CreateCommentSubjectResp, err error)
 	UpdateCommentSubject(ctx context.Context, req *platform.UpdateCommentSubjectReq) (resp *platform.UpdateCommentSubjectResp, err error)
 	DeleteCommentSubject(ctx context.Context, req *platform.DeleteCommentSubjectReq) (resp *platform.DeleteCommentSubjectResp, err error)
+	SetPreModeration(ctx context.Context, subjectId string, enabled bool) (err error)
+	DeleteSubjectCascade(ctx context.Context, subjectId string, batchSize int64, dryRun bool, progress func(p *DeleteSubjectProgress)) (p *DeleteSubjectProgress, err error)
+	GetDeleteStatus(ctx context.Context, subjectId string) (status *DeleteSubjectStatus, err error)
+	RunCascade(ctx context.Context)
 }
 
 type SubjectService struct {
-	SubjectMongoMapper      subjectMapper.IMongoMapper
-	DeleteSubjectRelationKq *kq.DeleteCommentRelationKq
+	Config                *config.Config
+	SubjectMongoMapper    subjectMapper.IMongoMapper
+	CommentMongoMapper    commentMapper.IMongoMapper
+	CommentEsMapper       commentMapper.IEsMapper
+	RevisionMongoMapper   revisionMapper.IMongoMapper
+	ReactionMongoMapper   reactionMapper.IMongoMapper
+	OutboxMongoMapper     outboxMapper.IMongoMapper
+	RecycleMongoMapper    recycleMapper.IMongoMapper
+	ModerationMongoMapper moderationMapper.IMongoMapper
+	ChangeMongoMapper     changeMapper.IMongoMapper
+}
+
+// defaultCascadeBatchSize 级联删除评论区时每批删除的评论数
+const defaultCascadeBatchSize = 100
+
+// DeleteSubjectProgress 级联删除评论区的进度，试运行时 Deleted 为将要删除的评论数
+type DeleteSubjectProgress struct {
+	SubjectId string `json:"subjectId"`
+	DryRun    bool   `json:"dryRun"`
+	Total     int64  `json:"total"`
+	Deleted   int64  `json:"deleted"`
+	Batches   int64  `json:"batches"`
+	LastId    string `json:"lastId"`
+	Done      bool   `json:"done"`
+}
+
+// DeleteSubjectStatus 评论区后台级联删除的状态
+type DeleteSubjectStatus struct {
+	SubjectId string `json:"subjectId"`
+	// Deleting 评论区已标记为删除中，Remaining 为尚未删除的评论数
+	Deleting  bool  `json:"deleting"`
+	Remaining int64 `json:"remaining"`
+	// Done 评论区已被彻底删除
+	Done bool `json:"done"`
 }
 
 var SubjectSet = wire.NewSet(
@@ -40,9 +83,13 @@ func (s *SubjectService) GetCommentSubject(ctx context.Context, req *platform.Ge
 		return resp, err
 	}
 
+	// 兼容只支持单条置顶的调用方，返回排在最前的置顶评论
+	if pins := data.ActivePins(time.Now()); len(pins) > 0 {
+		data.TopCommentId = lo.ToPtr(pins[0].CommentId)
+	}
 	resp = &platform.GetCommentSubjectResp{
 		UserId:       data.UserId,
-		TopCommentId: *data.TopCommentId,
+		TopCommentId: lo.FromPtr(data.TopCommentId),
 		RootCount:    *data.RootCount,
 		AllCount:     *data.AllCount,
 		State:        data.State,
@@ -59,14 +106,13 @@ func (s *SubjectService) CreateCommentSubject(ctx context.Context, req *platform
 		return resp, err
 	}
 	if _, err = s.SubjectMongoMapper.Insert(ctx, &subjectMapper.Subject{
-		ID:           oid,
-		UserId:       req.UserId,
-		TopCommentId: lo.ToPtr(""),
-		RootCount:    lo.ToPtr(int64(0)),
-		AllCount:     lo.ToPtr(int64(0)),
-		State:        int64(platform.State_Normal),
-		Attrs:        int64(platform.Attrs_None),
-		Type:         req.Type,
+		ID:        oid,
+		UserId:    req.UserId,
+		RootCount: lo.ToPtr(int64(0)),
+		AllCount:  lo.ToPtr(int64(0)),
+		State:     int64(platform.State_Normal),
+		Attrs:     int64(platform.Attrs_None),
+		Type:      req.Type,
 	}); err != nil {
 		log.CtxError(ctx, "创建评论区 失败[%v]\n", err)
 		return resp, err
@@ -74,10 +120,6 @@ func (s *SubjectService) CreateCommentSubject(ctx context.Context, req *platform
 	return resp, nil
 }
 
-func (s *SubjectService) UpdateCount(ctx context.Context, subjectId string, rootCount, allCount int64) {
-	s.SubjectMongoMapper.UpdateCount(ctx, subjectId, rootCount, allCount)
-}
-
 func (s *SubjectService) UpdateCommentSubject(ctx context.Context, req *platform.UpdateCommentSubjectReq) (resp *platform.UpdateCommentSubjectResp, err error) {
 	resp = new(platform.UpdateCommentSubjectResp)
 	var oid primitive.ObjectID
@@ -97,25 +139,275 @@ func (s *SubjectService) UpdateCommentSubject(ctx context.Context, req *platform
 	return resp, nil
 }
 
+// SetPreModeration 开启后该评论区的新评论进入待审核状态，审核通过后才对外可见
+func (s *SubjectService) SetPreModeration(ctx context.Context, subjectId string, enabled bool) (err error) {
+	var oid primitive.ObjectID
+	if oid, err = primitive.ObjectIDFromHex(subjectId); err != nil {
+		return consts.ErrInvalidId
+	}
+	if _, err = s.SubjectMongoMapper.Update(ctx, &subjectMapper.Subject{
+		ID:            oid,
+		PreModeration: lo.ToPtr(enabled),
+	}); err != nil {
+		log.CtxError(ctx, "修改评论区审核设置 失败[%v]\n", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteCommentSubject 把评论区标记为删除中后立即返回，评论区下的评论与关联数据由 RunCascade 在后台分批删除
 func (s *SubjectService) DeleteCommentSubject(ctx context.Context, req *platform.DeleteCommentSubjectReq) (resp *platform.DeleteCommentSubjectResp, err error) {
 	resp = new(platform.DeleteCommentSubjectResp)
 	var subject *subjectMapper.Subject
 	if subject, err = s.SubjectMongoMapper.FindOne(ctx, req.SubjectId); err != nil {
-		log.CtxError(ctx, "删除评论区 失败[%v]\n", err)
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return resp, err
+	}
+	if err = s.markDeleting(ctx, subject); err != nil {
 		return resp, err
 	}
+	return resp, nil
+}
+
+// GetDeleteStatus 返回评论区后台级联删除的状态，评论区不存在时视为已删除完成
+func (s *SubjectService) GetDeleteStatus(ctx context.Context, subjectId string) (status *DeleteSubjectStatus, err error) {
+	status = &DeleteSubjectStatus{SubjectId: subjectId}
+	var subject *subjectMapper.Subject
+	if subject, err = s.SubjectMongoMapper.FindOne(ctx, subjectId); errors.Is(err, consts.ErrNotFound) {
+		status.Done = true
+		return status, nil
+	} else if err != nil {
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return status, err
+	}
+	status.Deleting = subject.State == consts.SubjectDeletingState
+	if status.Remaining, err = s.CommentMongoMapper.Count(ctx, &commentMapper.FilterOptions{OnlySubjectId: lo.ToPtr(subjectId)}); err != nil {
+		log.CtxError(ctx, "统计评论区评论数 失败[%v]\n", err)
+		return status, err
+	}
+	return status, nil
+}
+
+// markDeleting 把评论区标记为删除中，拒绝新评论并等待后台任务删除
+func (s *SubjectService) markDeleting(ctx context.Context, subject *subjectMapper.Subject) error {
+	if subject.State == consts.SubjectDeletingState {
+		return nil
+	}
+	if _, err := s.SubjectMongoMapper.Update(ctx, &subjectMapper.Subject{ID: subject.ID, State: consts.SubjectDeletingState}); err != nil {
+		log.CtxError(ctx, "标记评论区删除中 失败[%v]\n", err)
+		return err
+	}
+	return nil
+}
 
-	if _, err = s.SubjectMongoMapper.Delete(ctx, req.SubjectId); err != nil {
+// RunCascade 按配置的间隔认领删除中的评论区并继续级联删除，直到 ctx 结束
+// 认领时写入租约，多个实例同时运行时同一评论区只由一个实例处理；处理中的实例退出后租约到期，由其他实例从剩余的数据继续删除
+func (s *SubjectService) RunCascade(ctx context.Context) {
+	conf := s.Config.Cascade
+	if conf.Interval <= 0 || conf.Lease <= 0 {
+		log.CtxError(ctx, "级联删除配置无效，不启动删除任务: 间隔[%v] 租约[%v]\n", conf.Interval, conf.Lease)
+		return
+	}
+	ticker := time.NewTicker(conf.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.cascadeDeleting(ctx)
+		}
+	}
+}
+
+// cascadeDeleting 依次认领并删除当前没有被其他实例处理的删除中评论区，失败的评论区在租约到期后重试
+func (s *SubjectService) cascadeDeleting(ctx context.Context) {
+	conf := s.Config.Cascade
+	for {
+		subject, err := s.SubjectMongoMapper.ClaimDeleting(ctx, conf.Lease)
+		if errors.Is(err, consts.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			log.CtxError(ctx, "认领删除中的评论区 失败[%v]\n", err)
+			return
+		}
+		subjectId := subject.ID.Hex()
+		p, err := s.DeleteSubjectCascade(ctx, subjectId, conf.BatchSize, false, func(p *DeleteSubjectProgress) {
+			if err1 := s.SubjectMongoMapper.RenewLease(ctx, subjectId, conf.Lease); err1 != nil {
+				log.CtxError(ctx, "续期评论区删除租约 失败[%v]\n", err1)
+			}
+			log.CtxInfo(ctx, "删除评论区[%s]: 第[%d]批 已删除评论[%d/%d]\n", p.SubjectId, p.Batches, p.Deleted, p.Total)
+		})
+		if err != nil {
+			log.CtxError(ctx, "级联删除评论区[%s] 失败[%v]\n", subjectId, err)
+			return
+		}
+		log.CtxInfo(ctx, "级联删除评论区[%s] 完成: 评论[%d]\n", subjectId, p.Deleted)
+	}
+}
+
+// DeleteSubjectCascade 分批删除评论区下的全部评论，每条评论都发送删除关联关系的消息，
+// 再清理回收站中的评论、审核记录与变更记录，最后删除评论区本身
+// 开始前把评论区标记为删除中以拒绝新评论；每批评论与其消息在同一事务中删除和写入，中断后重新执行会从剩余的数据继续
+// dryRun 为 true 时只统计将要删除的评论，不做任何修改；progress 在每批处理完后调用，可以为 nil
+func (s *SubjectService) DeleteSubjectCascade(ctx context.Context, subjectId string, batchSize int64, dryRun bool, progress func(p *DeleteSubjectProgress)) (p *DeleteSubjectProgress, err error) {
+	p = &DeleteSubjectProgress{SubjectId: subjectId, DryRun: dryRun}
+	if batchSize <= 0 {
+		batchSize = defaultCascadeBatchSize
+	}
+	var subject *subjectMapper.Subject
+	if subject, err = s.SubjectMongoMapper.FindOne(ctx, subjectId); err != nil {
+		log.CtxError(ctx, "获取评论区详情 失败[%v]\n", err)
+		return p, err
+	}
+	fopts := &commentMapper.FilterOptions{OnlySubjectId: lo.ToPtr(subjectId)}
+	if p.Total, err = s.CommentMongoMapper.Count(ctx, fopts); err != nil {
+		log.CtxError(ctx, "统计评论区评论数 失败[%v]\n", err)
+		return p, err
+	}
+	if !dryRun {
+		if err = s.markDeleting(ctx, subject); err != nil {
+			return p, err
+		}
+	}
+
+	var comments []*commentMapper.Comment
+	for {
+		if comments, err = s.CommentMongoMapper.FindBatch(ctx, fopts, p.LastId, batchSize); err != nil {
+			log.CtxError(ctx, "获取评论列表 失败[%v]\n", err)
+			return p, err
+		}
+		if len(comments) == 0 {
+			break
+		}
+		if !dryRun {
+			if err = s.deleteComments(ctx, comments); err != nil {
+				return p, err
+			}
+		}
+		p.Deleted += int64(len(comments))
+		p.Batches++
+		p.LastId = comments[len(comments)-1].ID.Hex()
+		if progress != nil {
+			progress(p)
+		}
+	}
+	if dryRun {
+		p.Done = true
+		return p, nil
+	}
+
+	if err = s.deleteRecycled(ctx, subjectId, batchSize); err != nil {
+		return p, err
+	}
+	if _, err = s.ModerationMongoMapper.DeleteBySubjectId(ctx, subjectId); err != nil {
+		log.CtxError(ctx, "删除评论区审核记录 失败[%v]\n", err)
+		return p, err
+	}
+	if _, err = s.ChangeMongoMapper.DeleteBySubjectId(ctx, subjectId); err != nil {
+		log.CtxError(ctx, "删除评论区变更记录 失败[%v]\n", err)
+		return p, err
+	}
+
+	// 评论区与其关联关系消息在同一事务中删除和写入
+	if err = withTransaction(ctx, s.SubjectMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+		var err1 error
+		if _, err1 = s.SubjectMongoMapper.Delete(sessionContext, subjectId); err1 != nil {
+			log.CtxError(sessionContext, "删除评论区 产生错误[%v]\n", err1)
+			return err1
+		}
+		if err1 = s.OutboxMongoMapper.InsertMany(sessionContext, []*outboxMapper.Outbox{newDeleteRelationOutbox(subject.Type, subjectId)}); err1 != nil {
+			log.CtxError(sessionContext, "写入删除评论区关联消息 产生错误[%v]\n", err1)
+			return err1
+		}
+		return nil
+	}); err != nil {
 		log.CtxError(ctx, "删除评论区 失败[%v]\n", err)
-		return resp, err
+		return p, err
 	}
-	// 发送删除评论区关联文件的消息
-	data, _ := sonic.Marshal(&message.DeleteCommentRelationsMessage{
-		FromType: subject.Type,
-		FromId:   req.SubjectId,
+	p.Done = true
+	return p, nil
+}
+
+// deleteComments 在同一事务中删除一批评论及其历史版本与表态，并写入每条评论的删除关联关系消息
+func (s *SubjectService) deleteComments(ctx context.Context, comments []*commentMapper.Comment) (err error) {
+	ids := lo.Map(comments, func(comment *commentMapper.Comment, _ int) string {
+		return comment.ID.Hex()
 	})
-	if err2 := s.DeleteSubjectRelationKq.Push(pconvertor.Bytes2String(data)); err2 != nil {
-		return resp, err2
+	if err = withTransaction(ctx, s.CommentMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+		var err1 error
+		if _, err1 = s.RevisionMongoMapper.DeleteByCommentIds(sessionContext, ids); err1 != nil {
+			log.CtxError(sessionContext, "删除评论历史版本 产生错误[%v]\n", err1)
+			return err1
+		}
+		if _, err1 = s.ReactionMongoMapper.DeleteByCommentIds(sessionContext, ids); err1 != nil {
+			log.CtxError(sessionContext, "删除评论表态 产生错误[%v]\n", err1)
+			return err1
+		}
+		if err1 = s.OutboxMongoMapper.InsertMany(sessionContext, lo.Map(comments, func(comment *commentMapper.Comment, _ int) *outboxMapper.Outbox {
+			return newDeleteRelationOutbox(comment.Type, comment.ID.Hex())
+		})); err1 != nil {
+			log.CtxError(sessionContext, "写入删除评论关联消息 产生错误[%v]\n", err1)
+			return err1
+		}
+		if _, err1 = s.CommentMongoMapper.DeleteMany(sessionContext, ids); err1 != nil {
+			log.CtxError(sessionContext, "删除评论 产生错误[%v]\n", err1)
+			return err1
+		}
+		return nil
+	}); err != nil {
+		log.CtxError(ctx, "删除评论 失败[%v]\n", err)
+		return err
+	}
+	if err = s.CommentEsMapper.Delete(ctx, ids); err != nil {
+		log.CtxError(ctx, "删除评论索引 失败[%v]\n", err)
+	}
+	return nil
+}
+
+// deleteRecycled 分批清理评论区在回收站中的评论，与 Purge 一样删除其历史版本与表态并发送删除关联关系的消息
+// 墓碑评论的正文仍在评论集合中，已由 deleteComments 处理，这里只移出回收站
+func (s *SubjectService) deleteRecycled(ctx context.Context, subjectId string, batchSize int64) (err error) {
+	var entries []*recycleMapper.Recycle
+	for {
+		if entries, err = s.RecycleMongoMapper.FindBySubjectId(ctx, subjectId, batchSize); err != nil {
+			log.CtxError(ctx, "获取回收站评论 失败[%v]\n", err)
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		ids := lo.Map(entries, func(entry *recycleMapper.Recycle, _ int) string {
+			return entry.ID.Hex()
+		})
+		recycled := lo.Filter(entries, func(entry *recycleMapper.Recycle, _ int) bool {
+			return !entry.Tombstone
+		})
+		if err = withTransaction(ctx, s.CommentMongoMapper.StartClient(), func(sessionContext mongo.SessionContext) error {
+			var err1 error
+			if _, err1 = s.RevisionMongoMapper.DeleteByCommentIds(sessionContext, ids); err1 != nil {
+				log.CtxError(sessionContext, "删除评论历史版本 产生错误[%v]\n", err1)
+				return err1
+			}
+			if _, err1 = s.ReactionMongoMapper.DeleteByCommentIds(sessionContext, ids); err1 != nil {
+				log.CtxError(sessionContext, "删除评论表态 产生错误[%v]\n", err1)
+				return err1
+			}
+			if err1 = s.OutboxMongoMapper.InsertMany(sessionContext, lo.Map(recycled, func(entry *recycleMapper.Recycle, _ int) *outboxMapper.Outbox {
+				return newDeleteRelationOutbox(entry.Comment.Type, entry.ID.Hex())
+			})); err1 != nil {
+				log.CtxError(sessionContext, "写入删除评论关联消息 产生错误[%v]\n", err1)
+				return err1
+			}
+			if _, err1 = s.RecycleMongoMapper.DeleteMany(sessionContext, ids); err1 != nil {
+				log.CtxError(sessionContext, "清理回收站 产生错误[%v]\n", err1)
+				return err1
+			}
+			return nil
+		}); err != nil {
+			log.CtxError(ctx, "清理评论区回收站 失败[%v]\n", err)
+			return err
+		}
 	}
-	return resp, nil
 }
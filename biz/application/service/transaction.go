@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// withTransaction 在事务中执行 fn，fn 返回错误时回滚
+// 同一评论区的并发写入会在评论区文档上产生写冲突，驱动会重试整个事务直到超时，因此 fn 可能被执行多次，重复执行时需要得到相同的结果
+func withTransaction(ctx context.Context, client *mongo.Client, fn func(sessionContext mongo.SessionContext) error) error {
+	return client.UseSession(ctx, func(sessionContext mongo.SessionContext) error {
+		_, err := sessionContext.WithTransaction(sessionContext, func(sessionContext mongo.SessionContext) (any, error) {
+			return nil, fn(sessionContext)
+		})
+		return err
+	})
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/samber/lo"
+	"sort"
+)
+
+const (
+	defaultTreeDepth   = 8
+	defaultTreeBreadth = 20
+)
+
+// CommentNode 评论树中的一个节点，Replies 为直接回复该评论的评论，按创建时间排序
+type CommentNode struct {
+	Comment *CommentDetail `json:"comment"`
+	Replies []*CommentNode `json:"replies,omitempty"`
+	// More 因深度或广度限制而未返回的直接回复数
+	More int64 `json:"more"`
+}
+
+// GetCommentTree 按 FatherId 还原一级评论下的回复树，maxDepth 与 maxBreadth 分别限制层数与每层的回复数，不大于 0 时使用默认值
+func (s *CommentService) GetCommentTree(ctx context.Context, rootId string, maxDepth, maxBreadth int64) (root *CommentNode, err error) {
+	var data *commentMapper.Comment
+	if data, err = s.CommentMongoMapper.FindOne(ctx, rootId); err != nil {
+		log.CtxError(ctx, "获取评论详情 失败[%v]\n", err)
+		return nil, err
+	}
+	// 与回复一样，不展示的一级评论视为不存在
+	if lo.Contains(consts.InvisibleStates, data.State) {
+		return nil, consts.ErrNotFound
+	}
+	if data.RootId != data.SubjectId {
+		return nil, consts.ErrIllegalOperation
+	}
+	if maxDepth <= 0 {
+		maxDepth = defaultTreeDepth
+	}
+	if maxBreadth <= 0 {
+		maxBreadth = defaultTreeBreadth
+	}
+
+	var replies []*commentMapper.Comment
+	if replies, err = s.findReplies(ctx, rootId); err != nil {
+		return nil, err
+	}
+	return buildCommentNode(data, groupReplies(rootId, replies), maxDepth, maxBreadth), nil
+}
+
+// groupReplies 按父评论分组回复，保持回复原有的顺序
+// 父评论已被物理删除的回复挂到一级评论下，避免整段对话丢失
+func groupReplies(rootId string, replies []*commentMapper.Comment) map[string][]*commentMapper.Comment {
+	ids := lo.SliceToMap(replies, func(reply *commentMapper.Comment) (string, struct{}) {
+		return reply.ID.Hex(), struct{}{}
+	})
+	return lo.GroupBy(replies, func(reply *commentMapper.Comment) string {
+		if _, ok := ids[reply.FatherId]; ok {
+			return reply.FatherId
+		}
+		return rootId
+	})
+}
+
+// GetCommentChain 返回从一级评论到指定评论的回复链，用于展示两人之间的对话
+func (s *CommentService) GetCommentChain(ctx context.Context, commentId string, maxDepth int64) (chain []*CommentDetail, err error) {
+	var data *commentMapper.Comment
+	if data, err = s.CommentMongoMapper.FindOne(ctx, commentId); err != nil {
+		log.CtxError(ctx, "获取评论详情 失败[%v]\n", err)
+		return nil, err
+	}
+	if lo.Contains(consts.InvisibleStates, data.State) {
+		return nil, consts.ErrNotFound
+	}
+	if maxDepth <= 0 {
+		maxDepth = defaultTreeDepth
+	}
+	if data.RootId == data.SubjectId {
+		return []*CommentDetail{toCommentDetail(data)}, nil
+	}
+
+	var (
+		root    *commentMapper.Comment
+		replies []*commentMapper.Comment
+	)
+	if root, err = s.CommentMongoMapper.FindOne(ctx, data.RootId); err != nil {
+		log.CtxError(ctx, "获取一级评论 失败[%v]\n", err)
+		return nil, err
+	}
+	// 一级评论不展示时其下的回复也不展示
+	if lo.Contains(consts.InvisibleStates, root.State) {
+		return nil, consts.ErrNotFound
+	}
+	if replies, err = s.findReplies(ctx, data.RootId); err != nil {
+		return nil, err
+	}
+	byId := lo.KeyBy(replies, func(reply *commentMapper.Comment) string {
+		return reply.ID.Hex()
+	})
+
+	// 自下而上沿 FatherId 查找，父评论缺失时直接接到一级评论
+	chain = []*CommentDetail{toCommentDetail(data)}
+	for current := data; int64(len(chain)) < maxDepth; {
+		father, ok := byId[current.FatherId]
+		if !ok {
+			break
+		}
+		chain = append(chain, toCommentDetail(father))
+		current = father
+	}
+	chain = append(chain, toCommentDetail(root))
+	return lo.Reverse(chain), nil
+}
+
+func (s *CommentService) findReplies(ctx context.Context, rootId string) ([]*commentMapper.Comment, error) {
+	replies, err := s.CommentMongoMapper.FindAll(ctx, &commentMapper.FilterOptions{
+		OnlyRootId:    lo.ToPtr(rootId),
+		ExcludeStates: consts.InvisibleStates,
+	})
+	if err != nil {
+		log.CtxError(ctx, "获取回复列表 失败[%v]\n", err)
+		return nil, err
+	}
+	sort.SliceStable(replies, func(i, j int) bool {
+		return replies[i].CreateAt.Before(replies[j].CreateAt)
+	})
+	return replies, nil
+}
+
+func buildCommentNode(data *commentMapper.Comment, children map[string][]*commentMapper.Comment, depth, breadth int64) *CommentNode {
+	node := &CommentNode{Comment: toCommentDetail(data)}
+	replies := children[data.ID.Hex()]
+	if depth <= 0 {
+		node.More = int64(len(replies))
+		return node
+	}
+	if int64(len(replies)) > breadth {
+		node.More = int64(len(replies)) - breadth
+		replies = replies[:breadth]
+	}
+	node.Replies = lo.Map(replies, func(reply *commentMapper.Comment, _ int) *CommentNode {
+		return buildCommentNode(reply, children, depth-1, breadth)
+	})
+	return node
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	commentMapper "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"testing"
+)
+
+// treeShape 把评论树展开为 "评论 id: 子评论 id..." 的形式，并记录每个节点的 More，便于比较结构
+func treeShape(node *CommentNode, shape map[string][]string, more map[string]int64) {
+	shape[node.Comment.CommentId] = lo.Map(node.Replies, func(reply *CommentNode, _ int) string {
+		return reply.Comment.CommentId
+	})
+	if node.More > 0 {
+		more[node.Comment.CommentId] = node.More
+	}
+	for _, reply := range node.Replies {
+		treeShape(reply, shape, more)
+	}
+}
+
+func TestBuildCommentNode(t *testing.T) {
+	ids := make([]primitive.ObjectID, 7)
+	for i := range ids {
+		ids[i] = primitive.NewObjectID()
+	}
+	hex := func(i int) string { return ids[i].Hex() }
+	newComment := func(i, father int) *commentMapper.Comment {
+		return &commentMapper.Comment{ID: ids[i], RootId: hex(0), FatherId: hex(father), Count: lo.ToPtr(int64(0))}
+	}
+	root := &commentMapper.Comment{ID: ids[0], Count: lo.ToPtr(int64(0))}
+	// 0 ─┬─ 1 ─── 3 ─── 4
+	//    ├─ 2
+	//    └─ 5（父评论 6 已被删除）
+	replies := []*commentMapper.Comment{newComment(1, 0), newComment(2, 0), newComment(3, 1), newComment(4, 3), newComment(5, 6)}
+
+	tests := []struct {
+		name     string
+		depth    int64
+		breadth  int64
+		want     map[string][]string
+		wantMore map[string]int64
+	}{
+		{
+			name: "完整的树", depth: 8, breadth: 20,
+			want: map[string][]string{
+				hex(0): {hex(1), hex(2), hex(5)},
+				hex(1): {hex(3)},
+				hex(2): {},
+				hex(3): {hex(4)},
+				hex(4): {},
+				hex(5): {},
+			},
+			wantMore: map[string]int64{},
+		},
+		{
+			name: "深度限制", depth: 2, breadth: 20,
+			want: map[string][]string{
+				hex(0): {hex(1), hex(2), hex(5)},
+				hex(1): {hex(3)},
+				hex(2): {},
+				hex(3): {},
+				hex(5): {},
+			},
+			wantMore: map[string]int64{hex(3): 1},
+		},
+		{
+			name: "广度限制", depth: 8, breadth: 1,
+			want: map[string][]string{
+				hex(0): {hex(1)},
+				hex(1): {hex(3)},
+				hex(3): {hex(4)},
+				hex(4): {},
+			},
+			wantMore: map[string]int64{hex(0): 2},
+		},
+		{
+			name: "只返回根评论", depth: 0, breadth: 20,
+			want:     map[string][]string{hex(0): {}},
+			wantMore: map[string]int64{hex(0): 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shape, more := make(map[string][]string), make(map[string]int64)
+			treeShape(buildCommentNode(root, groupReplies(hex(0), replies), tt.depth, tt.breadth), shape, more)
+			if !reflect.DeepEqual(shape, tt.want) {
+				t.Errorf("tree = %v, want %v", shape, tt.want)
+			}
+			if !reflect.DeepEqual(more, tt.wantMore) {
+				t.Errorf("more = %v, want %v", more, tt.wantMore)
+			}
+		})
+	}
+}
+
+// treeMapper 只实现 FindOne 与 FindAll，FindAll 返回全部回复，不按条件过滤
+type treeMapper struct {
+	commentMapper.IMongoMapper
+	comments map[string]*commentMapper.Comment
+}
+
+func (m *treeMapper) FindOne(_ context.Context, id string) (*commentMapper.Comment, error) {
+	if data, ok := m.comments[id]; ok {
+		return data, nil
+	}
+	return nil, consts.ErrNotFound
+}
+
+func (m *treeMapper) FindAll(_ context.Context, _ *commentMapper.FilterOptions) ([]*commentMapper.Comment, error) {
+	return lo.Filter(lo.Values(m.comments), func(comment *commentMapper.Comment, _ int) bool {
+		return comment.RootId != comment.SubjectId
+	}), nil
+}
+
+func TestCommentTreeVisibility(t *testing.T) {
+	subjectId := primitive.NewObjectID().Hex()
+	tests := []struct {
+		name       string
+		rootState  int64
+		replyState int64
+		wantErr    error
+	}{
+		{name: "正常", rootState: consts.NormalState, replyState: consts.NormalState},
+		{name: "墓碑一级评论", rootState: consts.DeletedState, replyState: consts.NormalState},
+		{name: "待审核的一级评论", rootState: consts.PendingState, replyState: consts.NormalState, wantErr: consts.ErrNotFound},
+		{name: "隐藏的一级评论", rootState: consts.HiddenState, replyState: consts.NormalState, wantErr: consts.ErrNotFound},
+		{name: "审核不通过的一级评论", rootState: consts.RejectedState, replyState: consts.NormalState, wantErr: consts.ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := &commentMapper.Comment{ID: primitive.NewObjectID(), SubjectId: subjectId, RootId: subjectId, FatherId: subjectId, State: tt.rootState, Count: lo.ToPtr[int64](1)}
+			reply := &commentMapper.Comment{ID: primitive.NewObjectID(), SubjectId: subjectId, RootId: root.ID.Hex(), FatherId: root.ID.Hex(), State: tt.replyState, Count: lo.ToPtr[int64](0)}
+			s := &CommentService{CommentMongoMapper: &treeMapper{comments: map[string]*commentMapper.Comment{root.ID.Hex(): root, reply.ID.Hex(): reply}}}
+			if _, err := s.GetCommentTree(context.Background(), root.ID.Hex(), 0, 0); err != tt.wantErr {
+				t.Errorf("GetCommentTree() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := s.GetCommentChain(context.Background(), reply.ID.Hex(), 0); err != tt.wantErr {
+				t.Errorf("GetCommentChain() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommentChainInvisibleTarget(t *testing.T) {
+	subjectId := primitive.NewObjectID().Hex()
+	for _, state := range consts.InvisibleStates {
+		root := &commentMapper.Comment{ID: primitive.NewObjectID(), SubjectId: subjectId, RootId: subjectId, State: consts.NormalState, Count: lo.ToPtr[int64](0)}
+		reply := &commentMapper.Comment{ID: primitive.NewObjectID(), SubjectId: subjectId, RootId: root.ID.Hex(), FatherId: root.ID.Hex(), State: state, Count: lo.ToPtr[int64](0)}
+		s := &CommentService{CommentMongoMapper: &treeMapper{comments: map[string]*commentMapper.Comment{root.ID.Hex(): root, reply.ID.Hex(): reply}}}
+		if _, err := s.GetCommentChain(context.Background(), reply.ID.Hex(), 0); err != consts.ErrNotFound {
+			t.Errorf("状态 %d 的回复: GetCommentChain() error = %v, want %v", state, err, consts.ErrNotFound)
+		}
+	}
+}
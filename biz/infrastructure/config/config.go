@@ -6,6 +6,7 @@ import (
 	"github.com/zeromicro/go-zero/core/stores/cache"
 	"github.com/zeromicro/go-zero/core/stores/redis"
 	"os"
+	"time"
 )
 
 type ElasticsearchConf struct {
@@ -23,6 +24,90 @@ type EtcdConf struct {
 	Hosts []string
 }
 
+// SubjectTypeConf 按评论区类型配置的评论策略
+type SubjectTypeConf struct {
+	Type            int64
+	Tombstone       bool          `json:",optional"`                              // 删除被回复过的评论时保留占位而不是物理删除
+	SensitivePolicy string        `json:",optional,options=reject|mask|moderate"` // 命中敏感词时的处理方式，默认拒绝
+	RateLimit       RateLimitConf `json:",optional"`                              // 覆盖全局的发表评论限流规则
+}
+
+// RateLimitRule 滑动窗口限流规则，任意 Window 时长内最多允许 Limit 次，Limit 为 0 表示不限制
+type RateLimitRule struct {
+	Limit  int64         `json:",optional"`
+	Window time.Duration `json:",default=1m"`
+}
+
+// RateLimitConf 发表评论的限流配置
+type RateLimitConf struct {
+	User        RateLimitRule `json:",optional"` // 单个用户
+	UserSubject RateLimitRule `json:",optional"` // 单个用户在同一评论区
+	Subject     RateLimitRule `json:",optional"` // 单个评论区的突发流量
+	FailClosed  bool          `json:",optional"` // redis 不可用时拒绝发表评论，默认放行
+}
+
+// SensitiveConf 敏感词词库配置，Words 与 File 中的词合并使用
+type SensitiveConf struct {
+	Words          []string      `json:",optional"`
+	File           string        `json:",optional"`   // 每行一个词的词库文件
+	ReloadInterval time.Duration `json:",default=1m"` // 检查词库文件变更的间隔
+}
+
+// PinConf 评论置顶配置
+type PinConf struct {
+	Limit int64 `json:",default=3"` // 每个评论区最多置顶的评论数
+}
+
+// IdempotencyConf 创建类请求的幂等配置
+type IdempotencyConf struct {
+	TTL     time.Duration `json:",default=24h"` // 执行结果的保留时长，期间相同幂等键的请求直接返回该结果
+	LockTTL time.Duration `json:",default=10s"` // 请求执行中占用幂等键的最长时长
+}
+
+// OutboxConf 事务消息投递配置
+type OutboxConf struct {
+	Interval    time.Duration `json:",default=1s"`   // 检查待投递消息的间隔
+	BatchSize   int64         `json:",default=100"`  // 每批投递的消息数
+	MaxAttempts int64         `json:",default=10"`   // 超过后不再重试，标记为失败；不大于 0 时一直重试
+	Backoff     time.Duration `json:",default=1s"`   // 首次失败后的重试等待，之后每次翻倍
+	MaxBackoff  time.Duration `json:",default=10m"`  // 重试等待的上限
+	Lease       time.Duration `json:",default=1m"`   // 认领消息后独占投递的时长，超过后其他实例可以重新认领
+	Retention   time.Duration `json:",default=168h"` // 已投递消息的保留时长
+}
+
+// ChangeLogConf 评论变更记录配置
+type ChangeLogConf struct {
+	Retention time.Duration `json:",default=168h"` // 变更记录的保留时长，增量拉取与断线续传的水位不能早于此时长
+}
+
+// StreamConf 评论变更推送（SSE）配置，ListenOn 为空时不启动
+type StreamConf struct {
+	ListenOn     string        `json:",optional"`
+	Buffer       int           `json:",default=64"`  // 每个订阅的事件缓冲，写满后订阅被移出并从变更记录补齐
+	Heartbeat    time.Duration `json:",default=15s"` // 没有事件时发送心跳的间隔
+	ReplayBatch  int64         `json:",default=100"` // 从变更记录补齐时每批读取的变更数
+	PollInterval time.Duration `json:",default=1s"`  // 轮询变更记录的间隔，用于推送其他实例写入的变更
+}
+
+// GatewayConf IDL 中还没有对应 RPC 的接口以 HTTP JSON 提供，ListenOn 为空时不启动
+type GatewayConf struct {
+	ListenOn string `json:",optional"`
+}
+
+// CascadeConf 评论区级联删除任务配置
+type CascadeConf struct {
+	Interval  time.Duration `json:",default=1m"`  // 扫描删除中评论区的间隔
+	Lease     time.Duration `json:",default=10m"` // 认领评论区后独占处理的时长，每删除一批续期一次
+	BatchSize int64         `json:",default=100"` // 每批删除的评论数
+}
+
+// RecycleConf 评论回收站配置
+type RecycleConf struct {
+	Retention     time.Duration `json:",default=720h"` // 删除后可恢复的时长
+	PurgeInterval time.Duration `json:",default=1h"`   // 清理过期评论的间隔
+	BatchSize     int64         `json:",default=100"`  // 每批清理的评论数
+}
+
 type Config struct {
 	service.ServiceConf
 	ListenOn string
@@ -41,6 +126,47 @@ type Config struct {
 		Enable   bool
 	}
 	DeleteCommentRelationKq KqConfig
+	CommentMentionKq        KqConfig          `json:",optional"`
+	SubjectTypes            []SubjectTypeConf `json:",optional"`
+	Recycle                 RecycleConf
+	Cascade                 CascadeConf
+	Sensitive               SensitiveConf
+	Pin                     PinConf
+	RateLimit               RateLimitConf `json:",optional"`
+	Idempotency             IdempotencyConf
+	Outbox                  OutboxConf
+	Stream                  StreamConf  `json:",optional"`
+	Gateway                 GatewayConf `json:",optional"`
+	ChangeLog               ChangeLogConf
+}
+
+// GetSubjectTypeConf 返回评论区类型对应的策略，未配置的类型使用零值
+func (c *Config) GetSubjectTypeConf(subjectType int64) SubjectTypeConf {
+	for _, v := range c.SubjectTypes {
+		if v.Type == subjectType {
+			return v
+		}
+	}
+	return SubjectTypeConf{Type: subjectType}
+}
+
+// GetRateLimitConf 返回评论区类型对应的限流规则，类型中配置了 Limit 的规则覆盖全局规则，Limit 为负数表示该类型不限制
+// 全局或类型中任一开启 FailClosed 时，redis 不可用时拒绝该类型的评论
+func (c *Config) GetRateLimitConf(subjectType int64) RateLimitConf {
+	limit := c.RateLimit
+	override := c.GetSubjectTypeConf(subjectType).RateLimit
+	limit.User = overrideRule(limit.User, override.User)
+	limit.UserSubject = overrideRule(limit.UserSubject, override.UserSubject)
+	limit.Subject = overrideRule(limit.Subject, override.Subject)
+	limit.FailClosed = limit.FailClosed || override.FailClosed
+	return limit
+}
+
+func overrideRule(rule, override RateLimitRule) RateLimitRule {
+	if override.Limit != 0 {
+		return override
+	}
+	return rule
 }
 
 func NewConfig() (*Config, error) {
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"github.com/zeromicro/go-zero/core/conf"
+	"testing"
+	"time"
+)
+
+// minimalConfig 只包含必填项，不包含任何可选功能的配置段
+const minimalConfig = `
+Name: platform
+ListenOn: 0.0.0.0:8080
+Mongo:
+  URL: mongodb://localhost:27017
+  DB: platform
+CacheConf:
+  - Host: localhost:6379
+Elasticsearch:
+  Addresses:
+    - http://localhost:9200
+  Username: elastic
+  Password: elastic
+Redis:
+  Host: localhost:6379
+Neo4jConf:
+  Url: neo4j://localhost:7687
+  Username: neo4j
+  Password: neo4j
+  DataBase: neo4j
+  Enable: false
+DeleteCommentRelationKq:
+  Brokers:
+    - localhost:9092
+  Topic: delete_comment_relation
+`
+
+func loadMinimalConfig(t *testing.T) *Config {
+	t.Helper()
+	c := new(Config)
+	if err := conf.LoadFromYamlBytes([]byte(minimalConfig), c); err != nil {
+		t.Fatalf("LoadFromYamlBytes() error = %v", err)
+	}
+	return c
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c := loadMinimalConfig(t)
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{name: "Recycle.Retention", got: c.Recycle.Retention, want: 720 * time.Hour},
+		{name: "Recycle.PurgeInterval", got: c.Recycle.PurgeInterval, want: time.Hour},
+		{name: "Recycle.BatchSize", got: c.Recycle.BatchSize, want: int64(100)},
+		{name: "Sensitive.ReloadInterval", got: c.Sensitive.ReloadInterval, want: time.Minute},
+		{name: "Pin.Limit", got: c.Pin.Limit, want: int64(3)},
+		{name: "Cascade.Interval", got: c.Cascade.Interval, want: time.Minute},
+		{name: "Cascade.Lease", got: c.Cascade.Lease, want: 10 * time.Minute},
+		{name: "Cascade.BatchSize", got: c.Cascade.BatchSize, want: int64(100)},
+		{name: "Outbox.Interval", got: c.Outbox.Interval, want: time.Second},
+		{name: "Outbox.MaxAttempts", got: c.Outbox.MaxAttempts, want: int64(10)},
+		{name: "Outbox.Lease", got: c.Outbox.Lease, want: time.Minute},
+		{name: "Outbox.Retention", got: c.Outbox.Retention, want: 168 * time.Hour},
+		{name: "ChangeLog.Retention", got: c.ChangeLog.Retention, want: 168 * time.Hour},
+		{name: "Idempotency.TTL", got: c.Idempotency.TTL, want: 24 * time.Hour},
+		{name: "Idempotency.LockTTL", got: c.Idempotency.LockTTL, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRateLimitConfFailClosed(t *testing.T) {
+	tests := []struct {
+		name     string
+		global   bool
+		override bool
+		want     bool
+	}{
+		{name: "默认放行", want: false},
+		{name: "全局拒绝", global: true, want: true},
+		{name: "类型拒绝", override: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				RateLimit:    RateLimitConf{FailClosed: tt.global},
+				SubjectTypes: []SubjectTypeConf{{Type: 1, RateLimit: RateLimitConf{FailClosed: tt.override}}},
+			}
+			if got := c.GetRateLimitConf(1).FailClosed; got != tt.want {
+				t.Errorf("GetRateLimitConf().FailClosed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
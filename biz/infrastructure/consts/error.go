@@ -12,4 +12,15 @@ var (
 	ErrEsMapper              = status.Error(10008, "Es异常")
 	ErrIllegalOperation      = status.Error(10009, "非法操作")
 	ErrComponentNotStarted   = status.Error(10010, "该功能依赖的组件未启动")
+	ErrInvalidStateChange    = status.Error(10011, "评论状态不允许该变更")
+	ErrSensitiveContent      = status.Error(10012, "评论包含敏感词")
+	ErrPinLimit              = status.Error(10013, "置顶评论数量已达上限")
+	ErrRateLimited           = status.Error(10014, "评论过于频繁，请稍后再试")
+	ErrRequestInProgress     = status.Error(10015, "请求正在处理中，请勿重复提交")
+	ErrSubjectClosed         = status.Error(10016, "评论区已关闭")
+	ErrRepliesDisabled       = status.Error(10017, "评论区已禁止回复")
+	ErrOwnerReplyOnly        = status.Error(10018, "仅评论区所有者可以回复")
+	ErrSubjectArchived       = status.Error(10019, "评论区已归档，不能修改")
+	ErrInvalidHierarchy      = status.Error(10020, "评论的一级评论或父评论无效")
+	ErrStateConflict         = status.Error(10021, "评论状态已被修改，请刷新后重试")
 )
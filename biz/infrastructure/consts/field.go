@@ -28,6 +28,27 @@ const (
 	FromId       = "fromId"
 	FromType     = "fromType"
 	RelationType = "relationType"
+	SortTime     = "sortTime"
+	HeatValue    = "heatValue"
+	CommentId    = "commentId"
+	EditAt       = "editAt"
+	BatchId      = "batchId"
+	DeleteAt     = "deleteAt"
+	Mentions     = "mentions"
+	Pins         = "pins"
+	TopCommentId = "topCommentId"
+	Kind         = "kind"
+	Reactions    = "reactions"
+	NextAt       = "nextAt"
+	Attempts     = "attempts"
+	LastError    = "lastError"
+	Seq          = "seq"
+	Type         = "type"
+	Sensitive    = "sensitive"
+	Comment      = "comment"
+	LeaseAt      = "leaseAt"
+	DeliveredAt  = "deliveredAt"
+	Tombstone    = "tombstone"
 )
 
 const (
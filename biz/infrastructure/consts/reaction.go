@@ -0,0 +1,18 @@
+package consts
+
+import "github.com/samber/lo"
+
+// 评论表态类型
+const (
+	LikeReaction    = int64(1) // 赞
+	DislikeReaction = int64(2) // 踩
+	LaughReaction   = int64(3) // 笑
+	HeartReaction   = int64(4) // 爱心
+	CheerReaction   = int64(5) // 喝彩
+)
+
+var ReactionKinds = []int64{LikeReaction, DislikeReaction, LaughReaction, HeartReaction, CheerReaction}
+
+func IsReactionKind(kind int64) bool {
+	return lo.Contains(ReactionKinds, kind)
+}
@@ -0,0 +1,28 @@
+package consts
+
+import (
+	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform"
+	"github.com/samber/lo"
+)
+
+// 评论状态，在 platform.State 的基础上扩展
+const (
+	NormalState   = int64(platform.State_Normal) // 正常（审核通过）
+	HiddenState   = int64(platform.State_Hidden) // 隐藏
+	DeletedState  = int64(3)                     // 已删除，仅保留占位
+	PendingState  = int64(4)                     // 待审核
+	RejectedState = int64(5)                     // 审核不通过
+	FoldedState   = int64(6)                     // 折叠
+)
+
+var (
+	// InvisibleStates 不在评论区中展示的状态
+	InvisibleStates = []int64{HiddenState, PendingState, RejectedState}
+	// UncountedStates 不计入评论区与一级评论计数的状态
+	UncountedStates = []int64{HiddenState, PendingState, RejectedState, DeletedState}
+)
+
+// IsCounted 判断处于该状态的评论是否计入计数
+func IsCounted(state int64) bool {
+	return !lo.Contains(UncountedStates, state)
+}
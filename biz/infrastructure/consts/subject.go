@@ -0,0 +1,23 @@
+package consts
+
+import "github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform"
+
+// 评论区状态，在 platform.State 的基础上扩展
+const (
+	SubjectNormalState   = int64(platform.State_Normal) // 正常
+	SubjectHiddenState   = int64(platform.State_Hidden) // 隐藏
+	SubjectClosedState   = int64(3)                     // 关闭：不能发表新评论，已有评论仍可管理
+	SubjectArchivedState = int64(4)                     // 只读归档：不能发表、修改评论或变更评论属性
+	SubjectDeletingState = int64(5)                     // 级联删除中：不能发表新评论
+)
+
+// 评论区属性，按位组合，从第 8 位开始以免与 platform.Attrs 的取值重叠
+const (
+	SubjectRepliesDisabledAttr = int64(1 << 8) // 禁止回复，只能发表一级评论
+	SubjectOwnerReplyOnlyAttr  = int64(1 << 9) // 只有评论区所有者可以回复
+)
+
+// HasSubjectAttr 判断评论区属性中是否包含 attr
+func HasSubjectAttr(attrs, attr int64) bool {
+	return attrs&attr != 0
+}
@@ -85,3 +85,11 @@ func ConvertLabelAllFieldsSearchQuery(data string) []types.Query {
 		}},
 	}
 }
+
+func ConvertCommentContentSearchQuery(data string) []types.Query {
+	return []types.Query{{
+		Match: map[string]types.MatchQuery{
+			consts.Content: {Query: data},
+		}},
+	}
+}
@@ -0,0 +1,101 @@
+package event
+
+import (
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform"
+	"github.com/samber/lo"
+	"sync"
+)
+
+// Event 一条评论变更事件，Seq 与变更记录中的序号一致
+type Event struct {
+	SubjectId string            `json:"subjectId"`
+	Seq       int64             `json:"seq"`
+	Op        int64             `json:"op"`
+	CommentId string            `json:"commentId"`
+	RootId    string            `json:"rootId"`
+	Comment   *platform.Comment `json:"comment,omitempty"` // 评论已删除或不可见时为空
+}
+
+// Subscription 对一个评论区的订阅，C 被关闭表示订阅已被移出：可能是主动取消，也可能是消费过慢导致缓冲写满
+type Subscription struct {
+	SubjectId string
+	C         <-chan *Event
+	ch        chan *Event
+}
+
+// Bus 进程内的评论变更事件总线，发布时不阻塞，消费过慢的订阅会被直接移出，由订阅方从变更记录中补齐
+// 其他实例写入的变更由 StreamService.RunPoll 从变更记录中读取后发布
+type Bus struct {
+	buffer int
+	mu     sync.RWMutex
+	subs   map[string]map[*Subscription]struct{}
+}
+
+func NewBus(c *config.Config) *Bus {
+	return &Bus{
+		buffer: c.Stream.Buffer,
+		subs:   make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+func (b *Bus) Subscribe(subjectId string) *Subscription {
+	ch := make(chan *Event, b.buffer)
+	sub := &Subscription{SubjectId: subjectId, C: ch, ch: ch}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[subjectId] == nil {
+		b.subs[subjectId] = make(map[*Subscription]struct{})
+	}
+	b.subs[subjectId][sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe 移出订阅并关闭其 C，重复调用或订阅已被移出时不做处理
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs, ok := b.subs[sub.SubjectId]
+	if !ok {
+		return
+	}
+	if _, ok = subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(b.subs, sub.SubjectId)
+	}
+	close(sub.ch)
+}
+
+// Subjects 返回当前有订阅的评论区
+func (b *Bus) Subjects() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return lo.Keys(b.subs)
+}
+
+// Publish 按顺序把事件发给对应评论区的订阅
+func (b *Bus) Publish(events ...*Event) {
+	lagged := make(map[*Subscription]struct{})
+	b.mu.RLock()
+	for _, ev := range events {
+		for sub := range b.subs[ev.SubjectId] {
+			// 缓冲写满后不再发送后续事件，避免订阅方收到不连续的序号
+			if _, ok := lagged[sub]; ok {
+				continue
+			}
+			select {
+			case sub.ch <- ev:
+			default:
+				lagged[sub] = struct{}{}
+			}
+		}
+	}
+	b.mu.RUnlock()
+	// 只在写锁下关闭 channel，保证不会向已关闭的 channel 发送
+	for sub := range lagged {
+		b.Unsubscribe(sub)
+	}
+}
@@ -0,0 +1,89 @@
+package idempotent
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"strings"
+	"time"
+)
+
+const (
+	// MetaKey 客户端通过 kitex metainfo 传递幂等键时使用的 key
+	MetaKey = "IDEMPOTENCY_KEY"
+
+	prefixIdempotentKey = "idempotent:"
+	pendingValue        = "pending"
+	donePrefix          = "done:"
+)
+
+// Store 基于 redis 的幂等记录：同一个幂等键在有效期内只执行一次，重复请求直接返回首次执行的结果
+type Store struct {
+	redis *redis.Redis
+	conf  config.IdempotencyConf
+}
+
+func NewStore(c *config.Config, r *redis.Redis) *Store {
+	s := &Store{redis: r, conf: c.Idempotency}
+	if !s.enabled() {
+		log.Error("幂等配置无效，不对请求去重: TTL[%v] LockTTL[%v]", s.conf.TTL, s.conf.LockTTL)
+	}
+	return s
+}
+
+// KeyFromContext 读取请求携带的幂等键，未携带时返回空字符串
+func KeyFromContext(ctx context.Context) string {
+	if key, ok := metainfo.GetValue(ctx, MetaKey); ok {
+		return key
+	}
+	key, _ := metainfo.GetPersistentValue(ctx, MetaKey)
+	return key
+}
+
+// enabled 有效期不足一秒时 redis 无法设置过期时间，视为未开启幂等
+func (s *Store) enabled() bool {
+	return s.conf.TTL >= time.Second && s.conf.LockTTL >= time.Second
+}
+
+// Do 以 scope 与 key 去重执行 fn，key 为空或未开启幂等时直接执行
+// 首次执行成功后记录结果；执行失败时清除记录以便客户端重试；同一个键仍在执行中时返回 ErrRequestInProgress
+func (s *Store) Do(ctx context.Context, scope, key string, fn func() (string, error)) (string, error) {
+	if key == "" {
+		return fn()
+	}
+	if !s.enabled() {
+		return fn()
+	}
+	redisKey := prefixIdempotentKey + scope + ":" + key
+	ok, err := s.redis.SetnxExCtx(ctx, redisKey, pendingValue, int(s.conf.LockTTL.Seconds()))
+	if err != nil {
+		log.CtxError(ctx, "获取幂等记录 失败[%v]\n", err)
+		return "", err
+	}
+	if !ok {
+		var val string
+		if val, err = s.redis.GetCtx(ctx, redisKey); err != nil {
+			log.CtxError(ctx, "获取幂等记录 失败[%v]\n", err)
+			return "", err
+		}
+		if result, done := strings.CutPrefix(val, donePrefix); done {
+			return result, nil
+		}
+		return "", consts.ErrRequestInProgress
+	}
+
+	result, err := fn()
+	if err != nil {
+		if _, err1 := s.redis.DelCtx(ctx, redisKey); err1 != nil {
+			log.CtxError(ctx, "清除幂等记录 失败[%v]\n", err1)
+		}
+		return "", err
+	}
+	if err = s.redis.SetexCtx(ctx, redisKey, donePrefix+result, int(s.conf.TTL.Seconds())); err != nil {
+		log.CtxError(ctx, "保存幂等记录 失败[%v]\n", err)
+	}
+	return result, nil
+}
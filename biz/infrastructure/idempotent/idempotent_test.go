@@ -0,0 +1,29 @@
+package idempotent
+
+import (
+	"context"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"testing"
+	"time"
+)
+
+func TestDoWithoutValidConf(t *testing.T) {
+	tests := []struct {
+		name string
+		conf config.IdempotencyConf
+	}{
+		{name: "未配置"},
+		{name: "执行结果有效期为 0", conf: config.IdempotencyConf{LockTTL: 10 * time.Second}},
+		{name: "锁有效期不足一秒", conf: config.IdempotencyConf{TTL: time.Hour, LockTTL: 500 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 配置无效时不访问 redis，直接执行
+			s := &Store{conf: tt.conf}
+			got, err := s.Do(context.Background(), "comment:user", "key", func() (string, error) { return "id", nil })
+			if err != nil || got != "id" {
+				t.Errorf("Do() = (%q, %v), want (\"id\", nil)", got, err)
+			}
+		})
+	}
+}
@@ -1,17 +1,61 @@
 package kq
 
 import (
+	"context"
 	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/segmentio/kafka-go"
 	"github.com/zeromicro/go-queue/kq"
+	"strconv"
+	"time"
 )
 
+// DeleteCommentRelationKq 同步发送删除关联关系的消息，Push 返回 nil 时消息已被 broker 确认，供发件箱判断投递结果
 type DeleteCommentRelationKq struct {
-	*kq.Pusher
+	writer *kafka.Writer
 }
 
 func NewDeleteCommentRelationKq(c *config.Config) *DeleteCommentRelationKq {
-	pusher := kq.NewPusher(c.DeleteCommentRelationKq.Brokers, c.DeleteCommentRelationKq.Topic)
 	return &DeleteCommentRelationKq{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(c.DeleteCommentRelationKq.Brokers...),
+			Topic:        c.DeleteCommentRelationKq.Topic,
+			Balancer:     &kafka.LeastBytes{},
+			Compression:  kafka.Snappy,
+			RequiredAcks: kafka.RequireAll,
+			// 发件箱逐条同步发送，不等待凑满一批
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}
+}
+
+// Push 发送一条消息并等待所有副本确认
+func (k *DeleteCommentRelationKq) Push(ctx context.Context, v string) error {
+	return k.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(strconv.FormatInt(time.Now().UnixNano(), 10)),
+		Value: []byte(v),
+	})
+}
+
+// CommentMentionMessage 评论中提及用户的事件，每个被提及的用户一条
+type CommentMentionMessage struct {
+	CommentId       string `json:"commentId"`
+	SubjectId       string `json:"subjectId"`
+	UserId          string `json:"userId"`
+	MentionedUserId string `json:"mentionedUserId"`
+	Type            int64  `json:"type"`
+}
+
+type CommentMentionKq struct {
+	*kq.Pusher
+}
+
+// NewCommentMentionKq 未配置 CommentMentionKq 时返回 nil，不发送提及事件
+func NewCommentMentionKq(c *config.Config) *CommentMentionKq {
+	if len(c.CommentMentionKq.Brokers) == 0 || c.CommentMentionKq.Topic == "" {
+		return nil
+	}
+	pusher := kq.NewPusher(c.CommentMentionKq.Brokers, c.CommentMentionKq.Topic)
+	return &CommentMentionKq{
 		Pusher: pusher,
 	}
 }
@@ -0,0 +1,67 @@
+package limiter
+
+import (
+	"context"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zeromicro/go-zero/core/stringx"
+	"strconv"
+	"time"
+)
+
+// slidingWindowScript 先检查全部规则，全部通过后才在每个窗口中记录本次请求，避免某条规则拒绝时其余规则白白占用额度
+// KEYS 为各规则的 key，ARGV 依次为当前毫秒时间戳、本次请求的成员名，以及每条规则的窗口毫秒数与上限
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local member = ARGV[2]
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[2 * i + 1])
+	local limit = tonumber(ARGV[2 * i + 2])
+	redis.call("ZREMRANGEBYSCORE", key, 0, now - window)
+	if redis.call("ZCARD", key) >= limit then
+		return i
+	end
+end
+for i, key in ipairs(KEYS) do
+	redis.call("ZADD", key, now, member)
+	redis.call("PEXPIRE", key, tonumber(ARGV[2 * i + 1]))
+end
+return 0
+`)
+
+// Rule 一条滑动窗口限流规则：任意 Window 时长内最多允许 Limit 次
+type Rule struct {
+	Key    string
+	Limit  int64
+	Window time.Duration
+}
+
+// Limiter 基于 redis 有序集合的滑动窗口限流器
+type Limiter struct {
+	redis *redis.Redis
+}
+
+func NewLimiter(r *redis.Redis) *Limiter {
+	return &Limiter{redis: r}
+}
+
+// Allow 原子地检查并记录一次请求，任一规则超限时返回 false 且不记录，Limit 不大于 0 的规则被忽略
+func (l *Limiter) Allow(ctx context.Context, rules ...Rule) (bool, error) {
+	keys := make([]string, 0, len(rules))
+	args := []any{time.Now().UnixMilli(), stringx.Randn(16)}
+	for _, rule := range rules {
+		if rule.Limit <= 0 || rule.Window <= 0 {
+			continue
+		}
+		keys = append(keys, rule.Key)
+		args = append(args, strconv.FormatInt(rule.Window.Milliseconds(), 10), strconv.FormatInt(rule.Limit, 10))
+	}
+	if len(keys) == 0 {
+		return true, nil
+	}
+	res, err := l.redis.ScriptRunCtx(ctx, slidingWindowScript, keys, args...)
+	if err != nil {
+		return false, err
+	}
+	rejected, _ := res.(int64)
+	return rejected == 0, nil
+}
@@ -0,0 +1,126 @@
+package change
+
+import (
+	"context"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/stores/ttl"
+	"github.com/samber/lo"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"github.com/zeromicro/go-zero/core/trace"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const CollectionName = "comment_change"
+
+// 评论变更的类型
+const (
+	CreateOp  = int64(1) // 发表评论
+	EditOp    = int64(2) // 编辑内容
+	StateOp   = int64(3) // 审核等引起的状态变更
+	DeleteOp  = int64(4) // 删除或置为墓碑
+	RestoreOp = int64(5) // 从回收站恢复
+)
+
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type (
+	IMongoMapper interface {
+		InsertMany(ctx context.Context, data []*Change) error
+		EnsureIndexes(ctx context.Context) error
+		FindSince(ctx context.Context, subjectId string, seq int64, since time.Time, limit int64) ([]*Change, error)
+		DeleteBySubjectId(ctx context.Context, subjectId string) (int64, error)
+	}
+
+	// Change 评论区内的一次评论变更，Seq 在评论区内严格递增，作为增量拉取的水位
+	Change struct {
+		ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+		SubjectId string             `bson:"subjectId,omitempty" json:"subjectId,omitempty"`
+		Seq       int64              `bson:"seq,omitempty" json:"seq,omitempty"`
+		CommentId string             `bson:"commentId,omitempty" json:"commentId,omitempty"`
+		RootId    string             `bson:"rootId,omitempty" json:"rootId,omitempty"`
+		Op        int64              `bson:"op,omitempty" json:"op,omitempty"`
+		CreateAt  time.Time          `bson:"createAt,omitempty" json:"createAt,omitempty"`
+	}
+
+	MongoMapper struct {
+		conn      *monc.Model
+		retention time.Duration
+	}
+)
+
+func NewMongoMapper(config *config.Config) IMongoMapper {
+	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.CacheConf)
+	return &MongoMapper{
+		conn:      conn,
+		retention: config.ChangeLog.Retention,
+	}
+}
+
+// InsertMany 写入变更记录，需要与评论的修改及序号的分配放在同一事务中
+func (m *MongoMapper) InsertMany(ctx context.Context, data []*Change) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.InsertMany", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	if len(data) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, v := range data {
+		if v.ID.IsZero() {
+			v.ID = primitive.NewObjectID()
+		}
+		v.CreateAt = now
+	}
+	_, err := m.conn.InsertMany(ctx, lo.Map(data, func(v *Change, _ int) any {
+		return v
+	}))
+	return err
+}
+
+// FindSince 按序号升序返回评论区中序号大于 seq 的变更，since 不为零值时只返回该时间之后的变更
+func (m *MongoMapper) FindSince(ctx context.Context, subjectId string, seq int64, since time.Time, limit int64) ([]*Change, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.FindSince", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	filter := bson.M{consts.SubjectId: subjectId, consts.Seq: bson.M{"$gt": seq}}
+	if !since.IsZero() {
+		filter[consts.CreateAt] = bson.M{"$gt": since}
+	}
+	var data []*Change
+	if err := m.conn.Find(ctx, &data, filter, &options.FindOptions{
+		Sort:  bson.M{consts.Seq: 1},
+		Limit: &limit,
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DeleteBySubjectId 删除评论区的全部变更记录
+func (m *MongoMapper) DeleteBySubjectId(ctx context.Context, subjectId string) (int64, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.DeleteBySubjectId", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	return m.conn.DeleteMany(ctx, bson.M{consts.SubjectId: subjectId})
+}
+
+// EnsureIndexes 创建按评论区与序号拉取变更的索引，变更记录超过保留期后由 TTL 索引自动删除
+// 修改保留时长后重新执行会更新已有 TTL 索引的过期时长
+func (m *MongoMapper) EnsureIndexes(ctx context.Context) error {
+	if _, err := m.conn.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: consts.SubjectId, Value: 1}, {Key: consts.Seq, Value: 1}}, Options: options.Index().SetUnique(true)},
+	}); err != nil {
+		return err
+	}
+	return ttl.EnsureIndex(ctx, m.conn.Database().Collection(CollectionName), consts.CreateAt, m.retention)
+}
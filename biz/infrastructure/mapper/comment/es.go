@@ -0,0 +1,166 @@
+package comment
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/CloudStriver/go-pkg/utils/pagination"
+	"github.com/CloudStriver/go-pkg/utils/pagination/esp"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/samber/lo"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/trace"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"net/http"
+	"time"
+)
+
+type (
+	IEsMapper interface {
+		Search(ctx context.Context, query []types.Query, fopts *EsFilterOptions, popts *pagination.PaginationOptions, sorter esp.EsCursor) ([]*SearchHit, int64, error)
+		Index(ctx context.Context, data *Comment) error
+		Delete(ctx context.Context, ids []string) error
+	}
+
+	// SearchHit 一条评论搜索结果，Highlights 为 content 中命中关键词的片段
+	SearchHit struct {
+		ID         primitive.ObjectID
+		Score_     float64
+		Highlights []string
+	}
+
+	// esComment 写入索引的评论字段
+	esComment struct {
+		SubjectId string    `json:"subjectId"`
+		RootId    string    `json:"rootId"`
+		FatherId  string    `json:"fatherId"`
+		UserId    string    `json:"userId"`
+		Content   string    `json:"content"`
+		State     int64     `json:"state"`
+		Type      int64     `json:"type"`
+		CreateAt  time.Time `json:"createAt"`
+	}
+
+	EsMapper struct {
+		es        *elasticsearch.TypedClient
+		IndexName string
+	}
+)
+
+func (e *EsMapper) Search(ctx context.Context, query []types.Query, fopts *EsFilterOptions, popts *pagination.PaginationOptions, sorter esp.EsCursor) ([]*SearchHit, int64, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "elasticsearch.Search", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	p := esp.NewEsPaginator(pagination.NewRawStore(sorter), popts)
+	s, sa, err := p.MakeSortOptions(ctx)
+	if err != nil {
+		log.CtxError(ctx, "创建索引异常[%v]\n", err)
+		return nil, 0, err
+	}
+	res, err := e.es.Search().Index(e.IndexName).Request(&search.Request{
+		Query: &types.Query{
+			Bool: &types.BoolQuery{
+				Must:   query,
+				Filter: makeEsFilter(fopts),
+			},
+		},
+		Highlight: &types.Highlight{
+			Fields: map[string]types.HighlightField{
+				consts.Content: {PreTags: []string{"<em>"}, PostTags: []string{"</em>"}},
+			},
+		},
+		Sort:        s,
+		SearchAfter: sa,
+		Size:        lo.ToPtr(int(*popts.Limit)),
+	}).Do(ctx)
+	if err != nil {
+		logx.Errorf("es查询异常[%v]\n", err)
+		return nil, 0, err
+	}
+
+	total := res.Hits.Total.Value
+	hits := make([]*SearchHit, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		oid, err := primitive.ObjectIDFromHex(hit.Id_)
+		if err != nil {
+			return nil, 0, consts.ErrInvalidId
+		}
+		hits = append(hits, &SearchHit{
+			ID:         oid,
+			Score_:     float64(hit.Score_),
+			Highlights: hit.Highlight[consts.Content],
+		})
+	}
+
+	if *popts.Backward {
+		hits = lo.Reverse(hits)
+	}
+
+	// 更新游标
+	if len(hits) > 0 {
+		err = p.StoreCursor(ctx, hits[0], hits[len(hits)-1])
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return hits, total, nil
+}
+
+// Index 写入或覆盖评论的索引文档
+func (e *EsMapper) Index(ctx context.Context, data *Comment) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "elasticsearch.Index", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	_, err := e.es.Index(e.IndexName).Id(data.ID.Hex()).Document(&esComment{
+		SubjectId: data.SubjectId,
+		RootId:    data.RootId,
+		FatherId:  data.FatherId,
+		UserId:    data.UserId,
+		Content:   data.Content,
+		State:     data.State,
+		Type:      data.Type,
+		CreateAt:  data.CreateAt,
+	}).Do(ctx)
+	return err
+}
+
+func (e *EsMapper) Delete(ctx context.Context, ids []string) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "elasticsearch.Delete", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := e.es.DeleteByQuery(e.IndexName).Query(&types.Query{
+		Ids: &types.IdsQuery{Values: ids},
+	}).Do(ctx)
+	return err
+}
+
+func NewEsMapper(config *config.Config) IEsMapper {
+	esClient, err := elasticsearch.NewTypedClient(elasticsearch.Config{
+		Username:  config.Elasticsearch.Username,
+		Password:  config.Elasticsearch.Password,
+		Addresses: config.Elasticsearch.Addresses,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	})
+	if err != nil {
+		logx.Errorf("elasticsearch连接异常[%v]\n", err)
+	}
+	return &EsMapper{
+		es:        esClient,
+		IndexName: fmt.Sprintf("%s.%s", config.Mongo.DB, CollectionName),
+	}
+}
@@ -0,0 +1,46 @@
+package comment
+
+import (
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/samber/lo"
+	"strconv"
+)
+
+// EsFilterOptions 评论搜索的过滤条件，时间范围为毫秒时间戳且包含端点
+type EsFilterOptions struct {
+	OnlySubjectId *string
+	OnlyUserId    *string
+	OnlyStates    []int64
+	CreateAtFrom  *int64
+	CreateAtTo    *int64
+}
+
+func makeEsFilter(opts *EsFilterOptions) []types.Query {
+	if opts == nil {
+		return nil
+	}
+	var filter []types.Query
+	if opts.OnlySubjectId != nil {
+		filter = append(filter, types.Query{Term: map[string]types.TermQuery{consts.SubjectId: {Value: *opts.OnlySubjectId}}})
+	}
+	if opts.OnlyUserId != nil {
+		filter = append(filter, types.Query{Term: map[string]types.TermQuery{consts.UserId: {Value: *opts.OnlyUserId}}})
+	}
+	if len(opts.OnlyStates) > 0 {
+		filter = append(filter, types.Query{Terms: &types.TermsQuery{TermsQuery: map[string]types.TermsQueryField{
+			consts.State: lo.Map(opts.OnlyStates, func(state int64, _ int) types.FieldValue { return state }),
+		}}})
+	}
+	if opts.CreateAtFrom != nil || opts.CreateAtTo != nil {
+		r := types.DateRangeQuery{Format: lo.ToPtr("epoch_millis")}
+		if opts.CreateAtFrom != nil {
+			r.Gte = lo.ToPtr(strconv.FormatInt(*opts.CreateAtFrom, 10))
+		}
+		if opts.CreateAtTo != nil {
+			r.Lte = lo.ToPtr(strconv.FormatInt(*opts.CreateAtTo, 10))
+		}
+		filter = append(filter, types.Query{Range: map[string]types.RangeQuery{consts.CreateAt: r}})
+	}
+	return filter
+}
@@ -5,15 +5,37 @@ import (
 	"github.com/samber/lo"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
 )
 
 type FilterOptions struct {
 	OnlyUserId     *string
 	OnlyAtUserId   *string
+	OnlySubjectId  *string
 	OnlyRootId     *string
+	OnlyFatherId   *string
 	OnlyCommentIds []string
 	OnlyState      *int64
 	OnlyAttrs      *int64
+	ExcludeStates  []int64
+	// ExcludeEmptyTombstones 排除已删除且没有剩余回复的评论
+	ExcludeEmptyTombstones bool
+	// OnlyMentionedUserId 内容中提及了该用户的评论
+	OnlyMentionedUserId *string
+	// ExcludeCommentIds 排除指定评论，用于把置顶评论从普通列表中剔除
+	ExcludeCommentIds []string
+	// OnlyLabels 带有其中任一标签的评论
+	OnlyLabels []string
+	// AllLabels 同时带有全部标签的评论
+	AllLabels []string
+	OnlyType  *int64
+	// CreateAtFrom、CreateAtTo 创建时间范围，单位毫秒，左闭右开
+	CreateAtFrom *int64
+	CreateAtTo   *int64
+	// HasReplies 按是否有回复筛选，回复本身没有回复数，只适用于一级评论
+	HasReplies *bool
+	// OnlyMissingHeat 尚未计算热度的历史评论
+	OnlyMissingHeat bool
 }
 
 type MongoFilter struct {
@@ -31,10 +53,21 @@ func makeMongoFilter(opts *FilterOptions) bson.M {
 func (f *MongoFilter) toBson() bson.M {
 	f.CheckOnlyUserId()
 	f.CheckOnlyAtUserId()
+	f.CheckOnlyMentionedUserId()
 	f.CheckOnlyCommentIds()
+	f.CheckExcludeCommentIds()
+	f.CheckOnlySubjectId()
 	f.CheckOnlyRootId()
+	f.CheckOnlyFatherId()
 	f.CheckOnlyState()
+	f.CheckExcludeStates()
 	f.CheckOnlyAttrs()
+	f.CheckExcludeEmptyTombstones()
+	f.CheckLabels()
+	f.CheckOnlyType()
+	f.CheckCreateAt()
+	f.CheckHasReplies()
+	f.CheckOnlyMissingHeat()
 	return f.m
 }
 
@@ -49,32 +82,124 @@ func (f *MongoFilter) CheckOnlyCommentIds() {
 	}
 }
 
+func (f *MongoFilter) CheckExcludeCommentIds() {
+	if f.OnlyCommentIds == nil && len(f.ExcludeCommentIds) > 0 {
+		f.m[consts.ID] = bson.M{
+			"$nin": lo.Map[string, primitive.ObjectID](f.ExcludeCommentIds, func(s string, _ int) primitive.ObjectID {
+				oid, _ := primitive.ObjectIDFromHex(s)
+				return oid
+			}),
+		}
+	}
+}
+
 func (f *MongoFilter) CheckOnlyUserId() {
 	if f.OnlyUserId != nil {
 		f.m[consts.UserId] = *f.OnlyUserId
 	}
 }
 
+func (f *MongoFilter) CheckOnlySubjectId() {
+	if f.OnlySubjectId != nil {
+		f.m[consts.SubjectId] = *f.OnlySubjectId
+	}
+}
+
 func (f *MongoFilter) CheckOnlyRootId() {
 	if f.OnlyRootId != nil {
 		f.m[consts.RootId] = *f.OnlyRootId
 	}
 }
 
+func (f *MongoFilter) CheckOnlyFatherId() {
+	if f.OnlyFatherId != nil {
+		f.m[consts.FatherId] = *f.OnlyFatherId
+	}
+}
+
 func (f *MongoFilter) CheckOnlyAtUserId() {
 	if f.OnlyAtUserId != nil {
 		f.m[consts.AtUserId] = *f.OnlyAtUserId
 	}
 }
 
+func (f *MongoFilter) CheckOnlyMentionedUserId() {
+	if f.OnlyMentionedUserId != nil {
+		f.m[consts.Mentions+"."+consts.UserId] = *f.OnlyMentionedUserId
+	}
+}
+
 func (f *MongoFilter) CheckOnlyState() {
 	if f.OnlyState != nil {
 		f.m[consts.State] = *f.OnlyState
 	}
 }
 
+func (f *MongoFilter) CheckExcludeStates() {
+	if f.OnlyState == nil && len(f.ExcludeStates) > 0 {
+		f.m[consts.State] = bson.M{"$nin": f.ExcludeStates}
+	}
+}
+
+func (f *MongoFilter) CheckExcludeEmptyTombstones() {
+	if f.ExcludeEmptyTombstones {
+		f.m["$or"] = bson.A{
+			bson.M{consts.State: bson.M{"$ne": consts.DeletedState}},
+			bson.M{consts.Count: bson.M{"$gt": 0}},
+		}
+	}
+}
+
 func (f *MongoFilter) CheckOnlyAttrs() {
 	if f.OnlyAttrs != nil {
 		f.m[consts.Attrs] = *f.OnlyAttrs
 	}
 }
+
+func (f *MongoFilter) CheckLabels() {
+	labels := bson.M{}
+	if len(f.OnlyLabels) > 0 {
+		labels["$in"] = f.OnlyLabels
+	}
+	if len(f.AllLabels) > 0 {
+		labels["$all"] = f.AllLabels
+	}
+	if len(labels) > 0 {
+		f.m[consts.Labels] = labels
+	}
+}
+
+func (f *MongoFilter) CheckOnlyType() {
+	if f.OnlyType != nil {
+		f.m[consts.Type] = *f.OnlyType
+	}
+}
+
+func (f *MongoFilter) CheckCreateAt() {
+	createAt := bson.M{}
+	if f.CreateAtFrom != nil {
+		createAt["$gte"] = time.UnixMilli(*f.CreateAtFrom)
+	}
+	if f.CreateAtTo != nil {
+		createAt["$lt"] = time.UnixMilli(*f.CreateAtTo)
+	}
+	if len(createAt) > 0 {
+		f.m[consts.CreateAt] = createAt
+	}
+}
+
+func (f *MongoFilter) CheckHasReplies() {
+	switch {
+	case f.HasReplies == nil:
+	case *f.HasReplies:
+		f.m[consts.Count] = bson.M{"$gt": 0}
+	default:
+		f.m[consts.Count] = bson.M{"$not": bson.M{"$gt": 0}}
+	}
+}
+
+func (f *MongoFilter) CheckOnlyMissingHeat() {
+	if f.OnlyMissingHeat {
+		f.m[consts.HeatValue] = bson.M{"$exists": false}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/CloudStriver/go-pkg/utils/util/log"
 	"github.com/CloudStriver/platform/biz/infrastructure/config"
 	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/sort"
 	"github.com/samber/lo"
 	"github.com/zeromicro/go-zero/core/mr"
 	"github.com/zeromicro/go-zero/core/stores/monc"
@@ -18,6 +19,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.opentelemetry.io/otel"
 	oteltrace "go.opentelemetry.io/otel/trace"
+	"strconv"
 	"time"
 )
 
@@ -29,12 +31,25 @@ var _ IMongoMapper = (*MongoMapper)(nil)
 type (
 	IMongoMapper interface {
 		Insert(ctx context.Context, data *Comment) (string, error)
+		EnsureIndexes(ctx context.Context) error
+		InsertMany(ctx context.Context, data []*Comment) error
 		FindOne(ctx context.Context, id string) (*Comment, error)
+		FindManyByIds(ctx context.Context, ids []string) (map[string]*Comment, error)
 		Update(ctx context.Context, data *Comment) (*mongo.UpdateResult, error)
-		UpdateCount(ctx context.Context, id string, count int64)
+		UpdateContent(ctx context.Context, id, content string, mentions []Mention, sensitive []string, editAt time.Time) error
+		UpdateState(ctx context.Context, id string, from, to int64) error
+		IncrCount(ctx context.Context, id string, delta int64) error
+		IncrReactions(ctx context.Context, id string, deltas map[int64]int64) error
+		RefreshHeat(ctx context.Context, fopts *FilterOptions) (int64, error)
 		Delete(ctx context.Context, id string) (int64, error)
+		SoftDelete(ctx context.Context, id string) error
+		RestoreTombstone(ctx context.Context, data *Comment) error
 		DeleteMany(ctx context.Context, ids []string) (int64, error)
 		Count(ctx context.Context, filter *FilterOptions) (int64, error)
+		CountReplies(ctx context.Context, subjectId string) (map[string]int64, error)
+		FindReplyPreviews(ctx context.Context, rootIds []string, fopts *FilterOptions, size int64, sorter mongop.MongoCursor) (map[string]*ReplyPreview, error)
+		FindAll(ctx context.Context, fopts *FilterOptions) ([]*Comment, error)
+		FindBatch(ctx context.Context, fopts *FilterOptions, lastId string, limit int64) ([]*Comment, error)
 		FindMany(ctx context.Context, fopts *FilterOptions, popts *pagination.PaginationOptions, sorter mongop.MongoCursor) ([]*Comment, error)
 		FindManyAndCount(ctx context.Context, fopts *FilterOptions, popts *pagination.PaginationOptions, sorter mongop.MongoCursor) ([]*Comment, int64, error)
 		GetConn() *monc.Model
@@ -56,8 +71,26 @@ type (
 		State     int64              `bson:"state,omitempty" json:"state,omitempty"`
 		Attrs     int64              `bson:"attrs,omitempty" json:"attrs,omitempty"`
 		CreateAt  time.Time          `bson:"createAt,omitempty" json:"createAt,omitempty"`
+		EditAt    time.Time          `bson:"editAt,omitempty" json:"editAt,omitempty"`
 		SortTime  int64              `bson:"sortTime,omitempty" json:"sortTime,omitempty"`
 		HeatValue float64            `bson:"heatValue,omitempty" json:"heatValue,omitempty"`
+		Sensitive []string           `bson:"sensitive,omitempty" json:"sensitive,omitempty"` // 创建时命中的敏感词，供审核追溯
+		Mentions  []Mention          `bson:"mentions,omitempty" json:"mentions,omitempty"`
+		Reactions map[string]int64   `bson:"reactions,omitempty" json:"reactions,omitempty"` // 各类表态的数量，键为表态类型
+	}
+
+	// Mention 评论内容中提及的用户，Offset 与 Length 按字符计
+	Mention struct {
+		UserId string `bson:"userId" json:"userId"`
+		Offset int64  `bson:"offset" json:"offset"`
+		Length int64  `bson:"length" json:"length"`
+	}
+
+	// ReplyPreview 一级评论下排在最前的若干条回复，Total 为满足条件的回复总数
+	ReplyPreview struct {
+		Replies []*Comment `bson:"replies"`
+		Total   int64      `bson:"total"`
+		Token   *string    `bson:"-"`
 	}
 
 	MongoMapper struct {
@@ -82,6 +115,7 @@ func (m *MongoMapper) Insert(ctx context.Context, data *Comment) (string, error)
 	}
 	data.CreateAt = time.Now()
 	data.SortTime = data.CreateAt.UnixMilli()
+	data.HeatValue = sort.HeatValue(lo.FromPtr(data.Count), lo.Sum(lo.Values(data.Reactions)), data.CreateAt)
 	key := prefixCommentCacheKey + data.ID.Hex()
 	ID, err := m.conn.InsertOne(ctx, key, data)
 	if err != nil {
@@ -90,6 +124,27 @@ func (m *MongoMapper) Insert(ctx context.Context, data *Comment) (string, error)
 	return ID.InsertedID.(primitive.ObjectID).Hex(), nil
 }
 
+// InsertMany 原样写回一批评论（保留 id 与创建时间），用于从回收站恢复
+func (m *MongoMapper) InsertMany(ctx context.Context, data []*Comment) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.InsertMany", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	if len(data) == 0 {
+		return nil
+	}
+	docs := lo.Map(data, func(item *Comment, _ int) any {
+		return item
+	})
+	keys := lo.Map(data, func(item *Comment, _ int) string {
+		return prefixCommentCacheKey + item.ID.Hex()
+	})
+	if _, err := m.conn.InsertMany(ctx, docs); err != nil {
+		return err
+	}
+	return m.conn.DelCache(ctx, keys...)
+}
+
 func (m *MongoMapper) FindOne(ctx context.Context, id string) (*Comment, error) {
 	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
 	_, span := tracer.Start(ctx, "mongo.FindOne", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
@@ -112,6 +167,34 @@ func (m *MongoMapper) FindOne(ctx context.Context, id string) (*Comment, error)
 	}
 }
 
+// FindManyByIds 逐个按 id 走缓存读取评论，返回以 id 为键的结果，不存在或 id 无效的评论不在结果中
+func (m *MongoMapper) FindManyByIds(ctx context.Context, ids []string) (map[string]*Comment, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.FindManyByIds", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	return mr.MapReduce(func(source chan<- string) {
+		for _, id := range lo.Uniq(ids) {
+			source <- id
+		}
+	}, func(id string, writer mr.Writer[*Comment], cancel func(error)) {
+		data, err := m.FindOne(ctx, id)
+		switch {
+		case errorx.Is(err, consts.ErrNotFound) || errorx.Is(err, consts.ErrInvalidId):
+		case err != nil:
+			cancel(err)
+		default:
+			writer.Write(data)
+		}
+	}, func(pipe <-chan *Comment, writer mr.Writer[map[string]*Comment], cancel func(error)) {
+		res := make(map[string]*Comment)
+		for data := range pipe {
+			res[data.ID.Hex()] = data
+		}
+		writer.Write(res)
+	}, mr.WithContext(ctx))
+}
+
 func (m *MongoMapper) Update(ctx context.Context, data *Comment) (*mongo.UpdateResult, error) {
 	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
 	_, span := tracer.Start(ctx, "mongo.Update", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
@@ -122,14 +205,110 @@ func (m *MongoMapper) Update(ctx context.Context, data *Comment) (*mongo.UpdateR
 	return res, err
 }
 
-func (m *MongoMapper) UpdateCount(ctx context.Context, id string, count int64) {
+// UpdateContent 替换评论内容及其提及列表与命中的敏感词，为空时一并清除，editAt 记为最后一次编辑的时间
+func (m *MongoMapper) UpdateContent(ctx context.Context, id, content string, mentions []Mention, sensitive []string, editAt time.Time) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.UpdateContent", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return consts.ErrInvalidId
+	}
+	set, unset := bson.M{consts.Content: content, consts.EditAt: editAt}, bson.M{}
+	if len(mentions) > 0 {
+		set[consts.Mentions] = mentions
+	} else {
+		unset[consts.Mentions] = ""
+	}
+	if len(sensitive) > 0 {
+		set[consts.Sensitive] = sensitive
+	} else {
+		unset[consts.Sensitive] = ""
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	key := prefixCommentCacheKey + id
+	_, err = m.conn.UpdateOne(ctx, key, bson.M{consts.ID: oid}, update)
+	return err
+}
+
+// UpdateState 仅当评论仍处于 from 状态时变更为 to，状态已被并发修改时返回 ErrStateConflict
+func (m *MongoMapper) UpdateState(ctx context.Context, id string, from, to int64) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.UpdateState", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return consts.ErrInvalidId
+	}
+	key := prefixCommentCacheKey + id
+	res, err := m.conn.UpdateOne(ctx, key, bson.M{consts.ID: oid, consts.State: from}, bson.M{"$set": bson.M{consts.State: to}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return consts.ErrStateConflict
+	}
+	return nil
+}
+
+func (m *MongoMapper) IncrCount(ctx context.Context, id string, delta int64) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.IncrCount", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return consts.ErrInvalidId
+	}
+	key := prefixCommentCacheKey + id
+	_, err = m.conn.UpdateOne(ctx, key, bson.M{consts.ID: oid}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{consts.Count: bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$" + consts.Count, 0}}, delta}}}}},
+		{{Key: "$set", Value: bson.M{consts.HeatValue: sort.HeatExpr()}}},
+	})
+	return err
+}
+
+// IncrReactions 按表态类型增减表态数量并重算热度，表态数量最少减到 0
+func (m *MongoMapper) IncrReactions(ctx context.Context, id string, deltas map[int64]int64) error {
 	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
-	_, span := tracer.Start(ctx, "mongo.UpdateCount", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	_, span := tracer.Start(ctx, "mongo.IncrReactions", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
 	defer span.End()
 
-	oid, _ := primitive.ObjectIDFromHex(id)
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return consts.ErrInvalidId
+	}
+	set := bson.M{}
+	for kind, delta := range deltas {
+		field := consts.Reactions + "." + strconv.FormatInt(kind, 10)
+		set[field] = bson.M{"$max": bson.A{0, bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$" + field, 0}}, delta}}}}
+	}
 	key := prefixCommentCacheKey + id
-	_, _ = m.conn.UpdateOne(ctx, key, bson.M{consts.ID: oid}, bson.M{"$set": bson.M{consts.Count: lo.ToPtr(count)}})
+	_, err = m.conn.UpdateOne(ctx, key, bson.M{consts.ID: oid}, mongo.Pipeline{
+		{{Key: "$set", Value: set}},
+		{{Key: "$set", Value: bson.M{consts.HeatValue: sort.HeatExpr()}}},
+	})
+	return err
+}
+
+// RefreshHeat 按当前计数重算满足条件的评论热度，用于补齐历史数据
+func (m *MongoMapper) RefreshHeat(ctx context.Context, fopts *FilterOptions) (int64, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.RefreshHeat", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	res, err := m.conn.UpdateManyNoCache(ctx, makeMongoFilter(fopts), mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{consts.HeatValue: sort.HeatExpr()}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
 }
 
 func (m *MongoMapper) Delete(ctx context.Context, id string) (int64, error) {
@@ -146,6 +325,52 @@ func (m *MongoMapper) Delete(ctx context.Context, id string) (int64, error) {
 	return resp, err
 }
 
+// SoftDelete 将评论标记为已删除并清空内容，保留其在评论树中的位置
+func (m *MongoMapper) SoftDelete(ctx context.Context, id string) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.SoftDelete", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return consts.ErrInvalidId
+	}
+	key := prefixCommentCacheKey + id
+	_, err = m.conn.UpdateOne(ctx, key, bson.M{consts.ID: oid}, bson.M{
+		"$set":   bson.M{consts.State: consts.DeletedState},
+		"$unset": bson.M{consts.Content: "", consts.Meta: "", consts.Labels: "", consts.Mentions: "", consts.Reactions: ""},
+	})
+	return err
+}
+
+// RestoreTombstone 将墓碑恢复为删除前的内容、状态与表态并重算热度，回复数沿用当前值；评论已不是墓碑时返回 ErrNotFound
+func (m *MongoMapper) RestoreTombstone(ctx context.Context, data *Comment) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.RestoreTombstone", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	key := prefixCommentCacheKey + data.ID.Hex()
+	filter := bson.M{consts.ID: data.ID, consts.State: consts.DeletedState}
+	res, err := m.conn.UpdateOne(ctx, key, filter, bson.M{"$set": bson.M{
+		consts.State:     data.State,
+		consts.Content:   data.Content,
+		consts.Meta:      data.Meta,
+		consts.Labels:    data.Labels,
+		consts.Mentions:  data.Mentions,
+		consts.Reactions: data.Reactions,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return consts.ErrNotFound
+	}
+	_, err = m.conn.UpdateOne(ctx, key, bson.M{consts.ID: data.ID}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{consts.HeatValue: sort.HeatExpr()}}},
+	})
+	return err
+}
+
 func (m *MongoMapper) DeleteMany(ctx context.Context, ids []string) (int64, error) {
 	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
 	_, span := tracer.Start(ctx, "mongo.DeleteMany", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
@@ -183,6 +408,141 @@ func (m *MongoMapper) Count(ctx context.Context, fopts *FilterOptions) (int64, e
 	return m.conn.CountDocuments(ctx, filter)
 }
 
+// CountReplies 按一级评论分组统计评论区内计入计数的回复数
+func (m *MongoMapper) CountReplies(ctx context.Context, subjectId string) (map[string]int64, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.CountReplies", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	var result []struct {
+		RootId string `bson:"_id"`
+		Count  int64  `bson:"count"`
+	}
+	if err := m.conn.Aggregate(ctx, &result, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			consts.SubjectId: subjectId,
+			consts.RootId:    bson.M{"$ne": subjectId},
+			consts.State:     bson.M{"$nin": consts.UncountedStates},
+		}}},
+		{{Key: "$group", Value: bson.M{consts.ID: "$" + consts.RootId, consts.Count: bson.M{"$sum": 1}}}},
+	}); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(result))
+	for _, v := range result {
+		counts[v.RootId] = v.Count
+	}
+	return counts, nil
+}
+
+// FindReplyPreviews 用一次聚合取出多个一级评论的回复预览与回复数，Token 可用于继续分页获取该评论的回复
+// 每个一级评论通过 $lookup 只读取排序后的前 size 条回复，回复数单独计数，不会把全部回复加载到内存
+func (m *MongoMapper) FindReplyPreviews(ctx context.Context, rootIds []string, fopts *FilterOptions, size int64, sorter mongop.MongoCursor) (map[string]*ReplyPreview, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.FindReplyPreviews", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	if len(rootIds) == 0 {
+		return map[string]*ReplyPreview{}, nil
+	}
+	oids := lo.FilterMap(rootIds, func(id string, _ int) (primitive.ObjectID, bool) {
+		oid, err := primitive.ObjectIDFromHex(id)
+		return oid, err == nil
+	})
+	sameRoot := bson.M{"$expr": bson.M{"$eq": bson.A{"$" + consts.RootId, "$$rootId"}}}
+	countFilter := makeMongoFilter(fopts)
+	filter := makeMongoFilter(fopts)
+	order, err := sorter.MakeSortOptions(filter, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []struct {
+		RootId       primitive.ObjectID `bson:"_id"`
+		ReplyPreview `bson:",inline"`
+	}
+	if err = m.conn.Aggregate(ctx, &result, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{consts.ID: bson.M{"$in": oids}}}},
+		{{Key: "$project", Value: bson.M{consts.ID: 1}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from": CollectionName,
+			"let":  bson.M{"rootId": bson.M{"$toString": "$" + consts.ID}},
+			"pipeline": mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"$and": bson.A{sameRoot, filter}}}},
+				{{Key: "$sort", Value: sort.OrderedSort(order)}},
+				{{Key: "$limit", Value: size}},
+			},
+			"as": "replies",
+		}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from": CollectionName,
+			"let":  bson.M{"rootId": bson.M{"$toString": "$" + consts.ID}},
+			"pipeline": mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"$and": bson.A{sameRoot, countFilter}}}},
+				{{Key: "$count", Value: "total"}},
+			},
+			"as": "total",
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"replies": 1,
+			"total":   bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$total.total", 0}}, 0}},
+		}}},
+	}); err != nil {
+		return nil, err
+	}
+
+	previews := make(map[string]*ReplyPreview, len(result))
+	for i := range result {
+		v := &result[i]
+		if v.Total == 0 {
+			continue
+		}
+		if n := len(v.Replies); n > 0 {
+			if v.Token, err = pagination.NewRawStore(sorter).StoreCursor(ctx, nil, v.Replies[0], v.Replies[n-1]); err != nil {
+				return nil, err
+			}
+		}
+		previews[v.RootId.Hex()] = &v.ReplyPreview
+	}
+	return previews, nil
+}
+
+func (m *MongoMapper) FindAll(ctx context.Context, fopts *FilterOptions) ([]*Comment, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.FindAll", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	var data []*Comment
+	if err := m.conn.Find(ctx, &data, makeMongoFilter(fopts)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// FindBatch 按 id 升序返回 lastId 之后满足条件的 limit 条评论，用于分批遍历
+func (m *MongoMapper) FindBatch(ctx context.Context, fopts *FilterOptions, lastId string, limit int64) ([]*Comment, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.FindBatch", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	filter := makeMongoFilter(fopts)
+	if lastId != "" {
+		oid, err := primitive.ObjectIDFromHex(lastId)
+		if err != nil {
+			return nil, consts.ErrInvalidId
+		}
+		filter[consts.ID] = bson.M{"$gt": oid}
+	}
+	var data []*Comment
+	if err := m.conn.Find(ctx, &data, filter, &options.FindOptions{
+		Sort:  bson.M{consts.ID: 1},
+		Limit: &limit,
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 func (m *MongoMapper) FindMany(ctx context.Context, fopts *FilterOptions, popts *pagination.PaginationOptions, sorter mongop.MongoCursor) ([]*Comment, error) {
 	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
 	_, span := tracer.Start(ctx, "mongo.FindMany", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
@@ -190,14 +550,14 @@ func (m *MongoMapper) FindMany(ctx context.Context, fopts *FilterOptions, popts
 
 	p := mongop.NewMongoPaginator(pagination.NewRawStore(sorter), popts)
 	filter := makeMongoFilter(fopts)
-	sort, err := p.MakeSortOptions(ctx, filter)
+	order, err := p.MakeSortOptions(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
 	var data []*Comment
 	if err = m.conn.Find(ctx, &data, filter, &options.FindOptions{
-		Sort:  sort,
+		Sort:  sort.OrderedSort(order),
 		Limit: popts.Limit,
 		Skip:  popts.Offset,
 	}); err != nil {
@@ -247,3 +607,25 @@ func (m *MongoMapper) GetConn() *monc.Model {
 func (m *MongoMapper) StartClient() *mongo.Client {
 	return m.conn.Database().Client()
 }
+
+// EnsureIndexes 创建评论列表、回复预览以及各类筛选条件使用的索引
+func (m *MongoMapper) EnsureIndexes(ctx context.Context) error {
+	_, err := m.conn.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// 一级评论与回复列表按最新、最热排序
+		{Keys: bson.D{{Key: consts.SubjectId, Value: 1}, {Key: consts.RootId, Value: 1}, {Key: consts.SortTime, Value: -1}}},
+		{Keys: bson.D{{Key: consts.SubjectId, Value: 1}, {Key: consts.RootId, Value: 1}, {Key: consts.HeatValue, Value: -1}, {Key: consts.ID, Value: -1}}},
+		// 回复预览、评论树与按父评论统计回复
+		{Keys: bson.D{{Key: consts.RootId, Value: 1}, {Key: consts.CreateAt, Value: 1}}},
+		{Keys: bson.D{{Key: consts.FatherId, Value: 1}}},
+		// 用户在评论区中的评论、回复用户的评论与提及用户的评论
+		{Keys: bson.D{{Key: consts.UserId, Value: 1}, {Key: consts.SubjectId, Value: 1}, {Key: consts.CreateAt, Value: -1}}},
+		{Keys: bson.D{{Key: consts.AtUserId, Value: 1}, {Key: consts.CreateAt, Value: -1}}},
+		{Keys: bson.D{{Key: consts.Mentions + "." + consts.UserId, Value: 1}, {Key: consts.CreateAt, Value: -1}}},
+		// 按标签、类型与创建时间筛选
+		{Keys: bson.D{{Key: consts.Labels, Value: 1}, {Key: consts.CreateAt, Value: -1}}},
+		{Keys: bson.D{{Key: consts.Type, Value: 1}, {Key: consts.CreateAt, Value: -1}}},
+		// 审核队列与级联删除时按评论区分批读取
+		{Keys: bson.D{{Key: consts.SubjectId, Value: 1}, {Key: consts.State, Value: 1}, {Key: consts.CreateAt, Value: -1}}},
+	})
+	return err
+}
@@ -0,0 +1,161 @@
+package outbox
+
+import (
+	"context"
+	errorx "errors"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/stores/ttl"
+	"github.com/samber/lo"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"github.com/zeromicro/go-zero/core/trace"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const CollectionName = "outbox"
+
+// 消息的投递状态
+const (
+	PendingState   = int64(1) // 待投递
+	DeliveredState = int64(2) // 已投递
+	FailedState    = int64(3) // 超过最大重试次数，需要人工处理
+)
+
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type (
+	IMongoMapper interface {
+		InsertMany(ctx context.Context, data []*Outbox) error
+		EnsureIndexes(ctx context.Context) error
+		ClaimDue(ctx context.Context, lease time.Duration, limit int64) ([]*Outbox, error)
+		MarkDelivered(ctx context.Context, id primitive.ObjectID) error
+		MarkRetry(ctx context.Context, id primitive.ObjectID, state, attempts int64, nextAt time.Time, lastError string) error
+	}
+
+	// Outbox 与业务数据在同一事务中写入的待发送消息，由投递任务异步发送到 Topic 对应的消息队列
+	Outbox struct {
+		ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+		Topic     string             `bson:"topic,omitempty" json:"topic,omitempty"`
+		Payload   string             `bson:"payload,omitempty" json:"payload,omitempty"`
+		State     int64              `bson:"state,omitempty" json:"state,omitempty"`
+		Attempts  int64              `bson:"attempts,omitempty" json:"attempts,omitempty"`
+		LastError string             `bson:"lastError,omitempty" json:"lastError,omitempty"`
+		NextAt    time.Time          `bson:"nextAt,omitempty" json:"nextAt,omitempty"`
+		CreateAt  time.Time          `bson:"createAt,omitempty" json:"createAt,omitempty"`
+		UpdateAt  time.Time          `bson:"updateAt,omitempty" json:"updateAt,omitempty"`
+		// DeliveredAt 投递成功的时间，超过保留期后由 TTL 索引删除
+		DeliveredAt time.Time `bson:"deliveredAt,omitempty" json:"deliveredAt,omitempty"`
+	}
+
+	MongoMapper struct {
+		conn      *monc.Model
+		retention time.Duration
+	}
+)
+
+func NewMongoMapper(config *config.Config) IMongoMapper {
+	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.CacheConf)
+	return &MongoMapper{
+		conn:      conn,
+		retention: config.Outbox.Retention,
+	}
+}
+
+// InsertMany 写入待投递的消息，需要与业务数据的修改放在同一事务中
+func (m *MongoMapper) InsertMany(ctx context.Context, data []*Outbox) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.InsertMany", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	if len(data) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, v := range data {
+		if v.ID.IsZero() {
+			v.ID = primitive.NewObjectID()
+		}
+		v.State = PendingState
+		v.NextAt = now
+		v.CreateAt = now
+		v.UpdateAt = now
+	}
+	_, err := m.conn.InsertMany(ctx, lo.Map(data, func(v *Outbox, _ int) any {
+		return v
+	}))
+	return err
+}
+
+// ClaimDue 按下次投递时间认领至多 limit 条到期的待投递消息，认领时把下次投递时间推迟 lease
+// 其他实例在 lease 内不会重复认领；认领的实例中断后消息在 lease 后重新到期
+func (m *MongoMapper) ClaimDue(ctx context.Context, lease time.Duration, limit int64) ([]*Outbox, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.ClaimDue", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	data := make([]*Outbox, 0, limit)
+	for int64(len(data)) < limit {
+		now := time.Now()
+		var msg Outbox
+		err := m.conn.FindOneAndUpdateNoCache(ctx, &msg, bson.M{
+			consts.State:  PendingState,
+			consts.NextAt: bson.M{"$lte": now},
+		}, bson.M{
+			"$set": bson.M{consts.NextAt: now.Add(lease), consts.UpdateAt: now},
+		}, options.FindOneAndUpdate().SetSort(bson.M{consts.NextAt: 1}).SetReturnDocument(options.After))
+		if errorx.Is(err, monc.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return data, err
+		}
+		data = append(data, &msg)
+	}
+	return data, nil
+}
+
+func (m *MongoMapper) MarkDelivered(ctx context.Context, id primitive.ObjectID) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.MarkDelivered", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	now := time.Now()
+	_, err := m.conn.UpdateByIDNoCache(ctx, id, bson.M{"$set": bson.M{
+		consts.State:       DeliveredState,
+		consts.DeliveredAt: now,
+		consts.UpdateAt:    now,
+	}, "$inc": bson.M{consts.Attempts: 1}})
+	return err
+}
+
+// MarkRetry 记录一次投递失败，state 为 FailedState 时不再重试
+func (m *MongoMapper) MarkRetry(ctx context.Context, id primitive.ObjectID, state, attempts int64, nextAt time.Time, lastError string) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.MarkRetry", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	_, err := m.conn.UpdateByIDNoCache(ctx, id, bson.M{"$set": bson.M{
+		consts.State:     state,
+		consts.Attempts:  attempts,
+		consts.NextAt:    nextAt,
+		consts.LastError: lastError,
+		consts.UpdateAt:  time.Now(),
+	}})
+	return err
+}
+
+// EnsureIndexes 创建按状态与下次投递时间查找到期消息的索引，已投递的消息超过保留期后由 TTL 索引自动删除
+func (m *MongoMapper) EnsureIndexes(ctx context.Context) error {
+	if _, err := m.conn.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: consts.State, Value: 1}, {Key: consts.NextAt, Value: 1}}},
+	}); err != nil {
+		return err
+	}
+	return ttl.EnsureIndex(ctx, m.conn.Database().Collection(CollectionName), consts.DeliveredAt, m.retention)
+}
@@ -0,0 +1,119 @@
+package reaction
+
+import (
+	"context"
+	errorx "errors"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"github.com/zeromicro/go-zero/core/trace"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const CollectionName = "comment_reaction"
+
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type (
+	IMongoMapper interface {
+		Upsert(ctx context.Context, commentId, userId string, kind int64) (int64, error)
+		EnsureIndexes(ctx context.Context) error
+		Delete(ctx context.Context, commentId, userId string) (int64, error)
+		DeleteByCommentIds(ctx context.Context, commentIds []string) (int64, error)
+		FindByUser(ctx context.Context, userId string, commentIds []string) ([]*Reaction, error)
+	}
+
+	// Reaction 用户对评论的表态，每个用户对同一条评论只保留一种
+	Reaction struct {
+		ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+		CommentId string             `bson:"commentId,omitempty" json:"commentId,omitempty"`
+		UserId    string             `bson:"userId,omitempty" json:"userId,omitempty"`
+		Kind      int64              `bson:"kind,omitempty" json:"kind,omitempty"`
+		CreateAt  time.Time          `bson:"createAt,omitempty" json:"createAt,omitempty"`
+	}
+
+	MongoMapper struct {
+		conn *monc.Model
+	}
+)
+
+func NewMongoMapper(config *config.Config) IMongoMapper {
+	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.CacheConf)
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+// Upsert 设置用户对评论的表态，返回之前的表态类型，之前没有表态时返回 0
+func (m *MongoMapper) Upsert(ctx context.Context, commentId, userId string, kind int64) (int64, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.Upsert", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	var old Reaction
+	err := m.conn.FindOneAndUpdateNoCache(ctx, &old, bson.M{consts.CommentId: commentId, consts.UserId: userId}, bson.M{
+		"$set": bson.M{consts.Kind: kind, consts.CreateAt: time.Now()},
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before))
+	switch {
+	case errorx.Is(err, mongo.ErrNoDocuments):
+		return 0, nil
+	case err != nil:
+		return 0, err
+	default:
+		return old.Kind, nil
+	}
+}
+
+// Delete 取消用户对评论的表态，返回被取消的表态类型，没有表态时返回 0
+func (m *MongoMapper) Delete(ctx context.Context, commentId, userId string) (int64, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.Delete", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	var old Reaction
+	err := m.conn.FindOneAndDeleteNoCache(ctx, &old, bson.M{consts.CommentId: commentId, consts.UserId: userId})
+	switch {
+	case errorx.Is(err, mongo.ErrNoDocuments):
+		return 0, nil
+	case err != nil:
+		return 0, err
+	default:
+		return old.Kind, nil
+	}
+}
+
+func (m *MongoMapper) DeleteByCommentIds(ctx context.Context, commentIds []string) (int64, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.DeleteByCommentIds", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	return m.conn.DeleteMany(ctx, bson.M{consts.CommentId: bson.M{"$in": commentIds}})
+}
+
+// FindByUser 返回用户在给定评论中的全部表态
+func (m *MongoMapper) FindByUser(ctx context.Context, userId string, commentIds []string) ([]*Reaction, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.FindByUser", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	var data []*Reaction
+	if err := m.conn.Find(ctx, &data, bson.M{consts.UserId: userId, consts.CommentId: bson.M{"$in": commentIds}}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// EnsureIndexes 创建表态索引，同一用户对同一评论只能有一个表态
+func (m *MongoMapper) EnsureIndexes(ctx context.Context) error {
+	_, err := m.conn.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: consts.CommentId, Value: 1}, {Key: consts.UserId, Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: consts.UserId, Value: 1}, {Key: consts.CommentId, Value: 1}}},
+	})
+	return err
+}
@@ -0,0 +1,161 @@
+package recycle
+
+import (
+	"context"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
+	"github.com/samber/lo"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"github.com/zeromicro/go-zero/core/trace"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const CollectionName = "comment_recycle"
+
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type (
+	IMongoMapper interface {
+		InsertMany(ctx context.Context, data []*Recycle) error
+		EnsureIndexes(ctx context.Context) error
+		FindByBatchId(ctx context.Context, batchId string) ([]*Recycle, error)
+		FindExpired(ctx context.Context, before time.Time, limit int64) ([]*Recycle, error)
+		FindBySubjectId(ctx context.Context, subjectId string, limit int64) ([]*Recycle, error)
+		DeleteMany(ctx context.Context, ids []string) (int64, error)
+		MoveToBatch(ctx context.Context, ids []string, batchId string) error
+	}
+
+	// Recycle 回收站中的一条评论，同一次删除操作移入的评论共享 BatchId（即被删除评论的 id）
+	// Tombstone 为 true 时评论仍以墓碑留在评论集合中，Comment 为删除前的快照，恢复时写回内容而不是重新插入
+	Recycle struct {
+		ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+		BatchId   string             `bson:"batchId,omitempty" json:"batchId,omitempty"`
+		Comment   *comment.Comment   `bson:"comment,omitempty" json:"comment,omitempty"`
+		Tombstone bool               `bson:"tombstone,omitempty" json:"tombstone,omitempty"`
+		DeleteAt  time.Time          `bson:"deleteAt,omitempty" json:"deleteAt,omitempty"`
+	}
+
+	MongoMapper struct {
+		conn *monc.Model
+	}
+)
+
+func NewMongoMapper(config *config.Config) IMongoMapper {
+	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.CacheConf)
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+func (m *MongoMapper) InsertMany(ctx context.Context, data []*Recycle) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.InsertMany", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	if len(data) == 0 {
+		return nil
+	}
+	now := time.Now()
+	docs := lo.Map(data, func(item *Recycle, _ int) any {
+		item.ID = item.Comment.ID
+		item.DeleteAt = now
+		return item
+	})
+	_, err := m.conn.InsertMany(ctx, docs)
+	return err
+}
+
+func (m *MongoMapper) FindByBatchId(ctx context.Context, batchId string) ([]*Recycle, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.FindByBatchId", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	var data []*Recycle
+	if err := m.conn.Find(ctx, &data, bson.M{consts.BatchId: batchId}); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, consts.ErrNotFound
+	}
+	return data, nil
+}
+
+// FindExpired 返回删除时间早于 before 的至多 limit 条评论，按删除时间升序
+func (m *MongoMapper) FindExpired(ctx context.Context, before time.Time, limit int64) ([]*Recycle, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.FindExpired", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	var data []*Recycle
+	if err := m.conn.Find(ctx, &data, bson.M{consts.DeleteAt: bson.M{"$lt": before}}, &options.FindOptions{
+		Sort:  bson.M{consts.DeleteAt: 1},
+		Limit: &limit,
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// FindBySubjectId 返回评论区中至多 limit 条仍在回收站中的评论
+func (m *MongoMapper) FindBySubjectId(ctx context.Context, subjectId string, limit int64) ([]*Recycle, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.FindBySubjectId", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	var data []*Recycle
+	if err := m.conn.Find(ctx, &data, bson.M{consts.Comment + "." + consts.SubjectId: subjectId}, &options.FindOptions{
+		Limit: &limit,
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (m *MongoMapper) DeleteMany(ctx context.Context, ids []string) (int64, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.DeleteMany", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	oids := lo.Map(ids, func(id string, _ int) primitive.ObjectID {
+		oid, _ := primitive.ObjectIDFromHex(id)
+		return oid
+	})
+	return m.conn.DeleteMany(ctx, bson.M{consts.ID: bson.M{"$in": oids}})
+}
+
+// MoveToBatch 把墓碑评论已有的快照并入 batchId 对应的删除批次，评论被彻底删除后恢复时重新插入
+func (m *MongoMapper) MoveToBatch(ctx context.Context, ids []string, batchId string) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.MoveToBatch", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	if len(ids) == 0 {
+		return nil
+	}
+	oids := lo.Map(ids, func(id string, _ int) primitive.ObjectID {
+		oid, _ := primitive.ObjectIDFromHex(id)
+		return oid
+	})
+	_, err := m.conn.UpdateManyNoCache(ctx, bson.M{consts.ID: bson.M{"$in": oids}}, bson.M{
+		"$set":   bson.M{consts.BatchId: batchId},
+		"$unset": bson.M{consts.Tombstone: ""},
+	})
+	return err
+}
+
+// EnsureIndexes 创建按删除批次恢复、按删除时间清理与删除评论区时清理的索引
+func (m *MongoMapper) EnsureIndexes(ctx context.Context) error {
+	_, err := m.conn.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: consts.BatchId, Value: 1}}},
+		{Keys: bson.D{{Key: consts.DeleteAt, Value: 1}}},
+		{Keys: bson.D{{Key: consts.Comment + "." + consts.SubjectId, Value: 1}}},
+	})
+	return err
+}
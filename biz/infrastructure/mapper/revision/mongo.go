@@ -0,0 +1,125 @@
+package revision
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/pagination"
+	"github.com/CloudStriver/go-pkg/utils/pagination/mongop"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/consts"
+	"github.com/zeromicro/go-zero/core/mr"
+	"github.com/zeromicro/go-zero/core/stores/monc"
+	"github.com/zeromicro/go-zero/core/trace"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const CollectionName = "comment_revision"
+
+var _ IMongoMapper = (*MongoMapper)(nil)
+
+type (
+	IMongoMapper interface {
+		Insert(ctx context.Context, data *Revision) (string, error)
+		EnsureIndexes(ctx context.Context) error
+		DeleteByCommentIds(ctx context.Context, commentIds []string) (int64, error)
+		FindManyAndCount(ctx context.Context, commentId string, popts *pagination.PaginationOptions, sorter mongop.MongoCursor) ([]*Revision, int64, error)
+	}
+
+	// Revision 记录评论被编辑前的内容
+	Revision struct {
+		ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+		CommentId string             `bson:"commentId,omitempty" json:"commentId,omitempty"`
+		UserId    string             `bson:"userId,omitempty" json:"userId,omitempty"`
+		Content   string             `bson:"content,omitempty" json:"content,omitempty"`
+		CreateAt  time.Time          `bson:"createAt,omitempty" json:"createAt,omitempty"`
+	}
+
+	MongoMapper struct {
+		conn *monc.Model
+	}
+)
+
+func NewMongoMapper(config *config.Config) IMongoMapper {
+	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.CacheConf)
+	return &MongoMapper{
+		conn: conn,
+	}
+}
+
+func (m *MongoMapper) Insert(ctx context.Context, data *Revision) (string, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.Insert", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	if data.ID.IsZero() {
+		data.ID = primitive.NewObjectID()
+	}
+	data.CreateAt = time.Now()
+	ID, err := m.conn.InsertOneNoCache(ctx, data)
+	if err != nil {
+		return "", err
+	}
+	return ID.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (m *MongoMapper) DeleteByCommentIds(ctx context.Context, commentIds []string) (int64, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.DeleteByCommentIds", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	return m.conn.DeleteMany(ctx, bson.M{consts.CommentId: bson.M{"$in": commentIds}})
+}
+
+func (m *MongoMapper) FindManyAndCount(ctx context.Context, commentId string, popts *pagination.PaginationOptions, sorter mongop.MongoCursor) ([]*Revision, int64, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.FindManyAndCount", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	var (
+		data       []*Revision
+		total      int64
+		err1, err2 error
+	)
+	err := mr.Finish(func() error {
+		p := mongop.NewMongoPaginator(pagination.NewRawStore(sorter), popts)
+		filter := bson.M{consts.CommentId: commentId}
+		sort, err := p.MakeSortOptions(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if err1 = m.conn.Find(ctx, &data, filter, &options.FindOptions{
+			Sort:  sort,
+			Limit: popts.Limit,
+			Skip:  popts.Offset,
+		}); err1 != nil {
+			return err1
+		}
+		// 如果是反向查询，反转数据
+		if *popts.Backward {
+			for i := 0; i < len(data)/2; i++ {
+				data[i], data[len(data)-i-1] = data[len(data)-i-1], data[i]
+			}
+		}
+		if len(data) > 0 {
+			return p.StoreCursor(ctx, data[0], data[len(data)-1])
+		}
+		return nil
+	}, func() error {
+		total, err2 = m.conn.CountDocuments(ctx, bson.M{consts.CommentId: commentId})
+		return err2
+	})
+	return data, total, err
+}
+
+// EnsureIndexes 创建按评论查询历史版本的索引
+func (m *MongoMapper) EnsureIndexes(ctx context.Context) error {
+	_, err := m.conn.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: consts.CommentId, Value: 1}, {Key: consts.ID, Value: -1}}},
+	})
+	return err
+}
@@ -10,6 +10,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.opentelemetry.io/otel"
 	oteltrace "go.opentelemetry.io/otel/trace"
 	"time"
@@ -24,24 +25,40 @@ type (
 	IMongoMapper interface {
 		Insert(ctx context.Context, data *Subject) (string, error)
 		FindOne(ctx context.Context, id string) (*Subject, error)
+		FindBatch(ctx context.Context, lastId string, limit int64) ([]*Subject, error)
 		Update(ctx context.Context, data *Subject) (*mongo.UpdateResult, error)
-		UpdateCount(ctx context.Context, id string, rootCount, allCount int64)
+		IncrCount(ctx context.Context, id string, rootDelta, allDelta int64) error
+		IncrSeq(ctx context.Context, id string, n int64) (int64, error)
+		SetPins(ctx context.Context, id string, pins []Pin) error
+		ClaimDeleting(ctx context.Context, lease time.Duration) (*Subject, error)
+		RenewLease(ctx context.Context, id string, lease time.Duration) error
 		Delete(ctx context.Context, id string) (int64, error)
 		GetConn() *monc.Model
 		StartClient() *mongo.Client
 	}
 
 	Subject struct {
-		ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-		Type         int64              `bson:"type,omitempty" json:"type,omitempty"`
-		UserId       string             `bson:"userId,omitempty" json:"userId,omitempty"`
-		TopCommentId *string            `bson:"topCommentId,omitempty" json:"topCommentId,omitempty"`
-		RootCount    *int64             `bson:"rootCount,omitempty" json:"rootCount,omitempty"`
-		AllCount     *int64             `bson:"allCount,omitempty" json:"allCount,omitempty"`
-		State        int64              `bson:"state,omitempty" json:"state,omitempty"`
-		Attrs        int64              `bson:"attrs,omitempty" json:"attrs,omitempty"`
-		CreateAt     time.Time          `bson:"createAt,omitempty" json:"createAt,omitempty"`
-		UpdateAt     time.Time          `bson:"updateAt,omitempty" json:"updateAt,omitempty"`
+		ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+		Type          int64              `bson:"type,omitempty" json:"type,omitempty"`
+		UserId        string             `bson:"userId,omitempty" json:"userId,omitempty"`
+		TopCommentId  *string            `bson:"topCommentId,omitempty" json:"topCommentId,omitempty"` // 已由 Pins 取代，仅用于迁移旧数据
+		Pins          []Pin              `bson:"pins,omitempty" json:"pins,omitempty"`                 // 按展示顺序排列的置顶评论
+		RootCount     *int64             `bson:"rootCount,omitempty" json:"rootCount,omitempty"`
+		AllCount      *int64             `bson:"allCount,omitempty" json:"allCount,omitempty"`
+		State         int64              `bson:"state,omitempty" json:"state,omitempty"`
+		Attrs         int64              `bson:"attrs,omitempty" json:"attrs,omitempty"`
+		CreateAt      time.Time          `bson:"createAt,omitempty" json:"createAt,omitempty"`
+		UpdateAt      time.Time          `bson:"updateAt,omitempty" json:"updateAt,omitempty"`
+		PreModeration *bool              `bson:"preModeration,omitempty" json:"preModeration,omitempty"` // 开启后新评论需审核通过才会展示
+		Seq           int64              `bson:"seq,omitempty" json:"seq,omitempty"`                     // 最后一次评论变更的序号
+		LeaseAt       time.Time          `bson:"leaseAt,omitempty" json:"leaseAt,omitempty"`             // 级联删除任务的租约到期时间
+	}
+
+	// Pin 一条置顶评论，ExpireAt 为零值时永久置顶
+	Pin struct {
+		CommentId string    `bson:"commentId" json:"commentId"`
+		PinAt     time.Time `bson:"pinAt" json:"pinAt"`
+		ExpireAt  time.Time `bson:"expireAt,omitempty" json:"expireAt,omitempty"`
 	}
 
 	MongoMapper struct {
@@ -49,6 +66,17 @@ type (
 	}
 )
 
+// ActivePins 返回在 now 时仍未过期的置顶评论，保持原有顺序
+func (s *Subject) ActivePins(now time.Time) []Pin {
+	pins := make([]Pin, 0, len(s.Pins))
+	for _, pin := range s.Pins {
+		if pin.ExpireAt.IsZero() || pin.ExpireAt.After(now) {
+			pins = append(pins, pin)
+		}
+	}
+	return pins
+}
+
 func NewMongoMapper(config *config.Config) IMongoMapper {
 	conn := monc.MustNewModel(config.Mongo.URL, config.Mongo.DB, CollectionName, config.CacheConf)
 	return &MongoMapper{
@@ -96,6 +124,30 @@ func (m *MongoMapper) FindOne(ctx context.Context, id string) (*Subject, error)
 	}
 }
 
+// FindBatch 按 _id 升序返回 lastId 之后的至多 limit 个评论区，lastId 为空时从头开始
+func (m *MongoMapper) FindBatch(ctx context.Context, lastId string, limit int64) ([]*Subject, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.FindBatch", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	filter := bson.M{}
+	if lastId != "" {
+		oid, err := primitive.ObjectIDFromHex(lastId)
+		if err != nil {
+			return nil, consts.ErrInvalidId
+		}
+		filter[consts.ID] = bson.M{"$gt": oid}
+	}
+	var data []*Subject
+	if err := m.conn.Find(ctx, &data, filter, &options.FindOptions{
+		Sort:  bson.M{consts.ID: 1},
+		Limit: &limit,
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 func (m *MongoMapper) Update(ctx context.Context, data *Subject) (*mongo.UpdateResult, error) {
 	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
 	_, span := tracer.Start(ctx, "mongo.Update", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
@@ -106,14 +158,110 @@ func (m *MongoMapper) Update(ctx context.Context, data *Subject) (*mongo.UpdateR
 	return res, err
 }
 
-func (m *MongoMapper) UpdateCount(ctx context.Context, id string, rootCount, allCount int64) {
+func (m *MongoMapper) IncrCount(ctx context.Context, id string, rootDelta, allDelta int64) error {
 	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
-	_, span := tracer.Start(ctx, "mongo.UpdateCount", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	_, span := tracer.Start(ctx, "mongo.IncrCount", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
 	defer span.End()
 
-	oid, _ := primitive.ObjectIDFromHex(id)
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return consts.ErrInvalidId
+	}
+	key := prefixSubjectCacheKey + id
+	_, err = m.conn.UpdateOne(ctx, key, bson.M{consts.ID: oid}, bson.M{
+		"$inc": bson.M{consts.RootCount: rootDelta, consts.AllCount: allDelta},
+		"$set": bson.M{consts.UpdateAt: time.Now()},
+	})
+	return err
+}
+
+// IncrSeq 为评论区分配 n 个连续的变更序号，返回其中最大的序号
+func (m *MongoMapper) IncrSeq(ctx context.Context, id string, n int64) (int64, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.IncrSeq", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return 0, consts.ErrInvalidId
+	}
+	var data Subject
+	key := prefixSubjectCacheKey + id
+	if err = m.conn.FindOneAndUpdate(ctx, key, &data, bson.M{consts.ID: oid}, bson.M{
+		"$inc": bson.M{consts.Seq: n},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{consts.Seq: 1})); err != nil {
+		if errorx.Is(err, monc.ErrNotFound) {
+			return 0, consts.ErrNotFound
+		}
+		return 0, err
+	}
+	return data.Seq, nil
+}
+
+// SetPins 整体替换置顶列表，同时清除旧版的 topCommentId
+func (m *MongoMapper) SetPins(ctx context.Context, id string, pins []Pin) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.SetPins", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return consts.ErrInvalidId
+	}
+	if pins == nil {
+		pins = []Pin{}
+	}
+	key := prefixSubjectCacheKey + id
+	_, err = m.conn.UpdateOne(ctx, key, bson.M{consts.ID: oid}, bson.M{
+		"$set":   bson.M{consts.Pins: pins, consts.UpdateAt: time.Now()},
+		"$unset": bson.M{consts.TopCommentId: ""},
+	})
+	return err
+}
+
+// ClaimDeleting 认领一个删除中且没有有效租约的评论区，租约在 lease 后到期，没有可认领的评论区时返回 ErrNotFound
+func (m *MongoMapper) ClaimDeleting(ctx context.Context, lease time.Duration) (*Subject, error) {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.ClaimDeleting", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	now := time.Now()
+	var data Subject
+	if err := m.conn.FindOneAndUpdateNoCache(ctx, &data, bson.M{
+		consts.State: consts.SubjectDeletingState,
+		"$or": bson.A{
+			bson.M{consts.LeaseAt: bson.M{"$exists": false}},
+			bson.M{consts.LeaseAt: bson.M{"$lt": now}},
+		},
+	}, bson.M{
+		"$set": bson.M{consts.LeaseAt: now.Add(lease)},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)); err != nil {
+		if errorx.Is(err, monc.ErrNotFound) {
+			return nil, consts.ErrNotFound
+		}
+		return nil, err
+	}
+	if err := m.conn.DelCache(ctx, prefixSubjectCacheKey+data.ID.Hex()); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// RenewLease 把评论区级联删除任务的租约延长到 lease 之后
+func (m *MongoMapper) RenewLease(ctx context.Context, id string, lease time.Duration) error {
+	tracer := otel.GetTracerProvider().Tracer(trace.TraceName)
+	_, span := tracer.Start(ctx, "mongo.RenewLease", oteltrace.WithSpanKind(oteltrace.SpanKindConsumer))
+	defer span.End()
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return consts.ErrInvalidId
+	}
 	key := prefixSubjectCacheKey + id
-	_, _ = m.conn.UpdateOne(ctx, key, bson.M{consts.ID: oid}, bson.M{"$set": bson.M{consts.RootCount: rootCount, consts.AllCount: allCount, consts.UpdateAt: time.Now()}})
+	_, err = m.conn.UpdateOne(ctx, key, bson.M{consts.ID: oid}, bson.M{
+		"$set": bson.M{consts.LeaseAt: time.Now().Add(lease)},
+	})
+	return err
 }
 
 func (m *MongoMapper) Delete(ctx context.Context, id string) (int64, error) {
@@ -0,0 +1,34 @@
+package subject
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSubjectActivePins(t *testing.T) {
+	now := time.Now()
+	forever := Pin{CommentId: "forever"}
+	future := Pin{CommentId: "future", ExpireAt: now.Add(time.Hour)}
+	expired := Pin{CommentId: "expired", ExpireAt: now.Add(-time.Hour)}
+	boundary := Pin{CommentId: "boundary", ExpireAt: now}
+	tests := []struct {
+		name string
+		pins []Pin
+		want []Pin
+	}{
+		{name: "没有置顶", want: []Pin{}},
+		{name: "永久置顶", pins: []Pin{forever}, want: []Pin{forever}},
+		{name: "过期的置顶被移除", pins: []Pin{expired, future}, want: []Pin{future}},
+		{name: "到期时刻视为已过期", pins: []Pin{boundary}, want: []Pin{}},
+		{name: "保持原有顺序", pins: []Pin{future, expired, forever}, want: []Pin{future, forever}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Subject{Pins: tt.pins}
+			if got := s.ActivePins(now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ActivePins() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
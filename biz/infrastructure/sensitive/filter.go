@@ -0,0 +1,107 @@
+package sensitive
+
+import (
+	"bufio"
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/samber/lo"
+	"github.com/zeromicro/go-zero/core/stringx"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	PolicyReject   = "reject"   // 拒绝发布
+	PolicyMask     = "mask"     // 替换为掩码后发布
+	PolicyModerate = "moderate" // 原文进入待审核状态
+)
+
+// Filter 基于多模式匹配的敏感词过滤器，词库来自配置与词库文件，文件变更后自动重新加载
+type Filter struct {
+	conf    config.SensitiveConf
+	trie    atomic.Value
+	modTime time.Time
+}
+
+func NewFilter(c *config.Config) *Filter {
+	f := &Filter{conf: c.Sensitive}
+	if err := f.Reload(); err != nil {
+		// 词库文件暂时不可用时先使用配置中的词，等待下次重新加载
+		log.Error("加载敏感词库 失败[%v]", err)
+		f.trie.Store(stringx.NewTrie(f.conf.Words))
+	}
+	return f
+}
+
+// Check 返回掩码后的文本与命中的敏感词，未命中时原样返回
+func (f *Filter) Check(text string) (masked string, words []string, found bool) {
+	return f.trie.Load().(stringx.Trie).Filter(text)
+}
+
+// Reload 重新读取词库文件并替换当前词库，文件未变更时跳过
+func (f *Filter) Reload() error {
+	words := f.conf.Words
+	if f.conf.File != "" {
+		info, err := os.Stat(f.conf.File)
+		if err != nil {
+			return err
+		}
+		if f.trie.Load() != nil && info.ModTime().Equal(f.modTime) {
+			return nil
+		}
+		fileWords, err := readWords(f.conf.File)
+		if err != nil {
+			return err
+		}
+		words = append(append([]string{}, f.conf.Words...), fileWords...)
+		f.modTime = info.ModTime()
+	}
+	f.trie.Store(stringx.NewTrie(lo.Uniq(words)))
+	return nil
+}
+
+// Watch 按配置的间隔检查词库文件，直到 ctx 结束
+func (f *Filter) Watch(ctx context.Context) {
+	if f.conf.File == "" {
+		return
+	}
+	if f.conf.ReloadInterval <= 0 {
+		log.CtxError(ctx, "敏感词库重新加载间隔无效[%v]，不再检查词库文件变更\n", f.conf.ReloadInterval)
+		return
+	}
+	ticker := time.NewTicker(f.conf.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.Reload(); err != nil {
+				log.CtxError(ctx, "重新加载敏感词库 失败[%v]\n", err)
+			}
+		}
+	}
+}
+
+// readWords 读取每行一个词的词库文件，忽略空行与 # 开头的注释
+func readWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	return words, scanner.Err()
+}
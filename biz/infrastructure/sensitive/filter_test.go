@@ -0,0 +1,86 @@
+package sensitive
+
+import (
+	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFilterCheck(t *testing.T) {
+	f := NewFilter(&config.Config{Sensitive: config.SensitiveConf{Words: []string{"坏词", "bad", "badword"}}})
+	tests := []struct {
+		name       string
+		text       string
+		wantMasked string
+		wantWords  []string
+		wantFound  bool
+	}{
+		{name: "未命中", text: "正常的评论", wantMasked: "正常的评论"},
+		{name: "中文", text: "这是坏词啊", wantMasked: "这是**啊", wantWords: []string{"坏词"}, wantFound: true},
+		{name: "重叠的词都会命中", text: "a badword", wantMasked: "a *******", wantWords: []string{"bad", "badword"}, wantFound: true},
+		{name: "多处命中", text: "bad 和 坏词", wantMasked: "*** 和 **", wantWords: []string{"bad", "坏词"}, wantFound: true},
+		{name: "空内容", text: "", wantMasked: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			masked, words, found := f.Check(tt.text)
+			sort.Strings(words)
+			sort.Strings(tt.wantWords)
+			if masked != tt.wantMasked || found != tt.wantFound || len(words) != len(tt.wantWords) || len(words) > 0 && !reflect.DeepEqual(words, tt.wantWords) {
+				t.Errorf("Check(%q) = (%q, %v, %v), want (%q, %v, %v)", tt.text, masked, words, found, tt.wantMasked, tt.wantWords, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestFilterReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte("# 注释\n\n  文件词  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f := NewFilter(&config.Config{Sensitive: config.SensitiveConf{Words: []string{"配置词"}, File: path}})
+	for _, text := range []string{"配置词", "文件词"} {
+		if _, _, found := f.Check(text); !found {
+			t.Errorf("Check(%q) found = false, want true", text)
+		}
+	}
+	if _, _, found := f.Check("注释"); found {
+		t.Error("注释行不应作为敏感词")
+	}
+
+	// 文件变更后重新加载，替换而不是追加文件中的词
+	if err := os.WriteFile(path, []byte("新词\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{text: "新词", want: true},
+		{text: "配置词", want: true},
+		{text: "文件词", want: false},
+	}
+	for _, tt := range tests {
+		if _, _, found := f.Check(tt.text); found != tt.want {
+			t.Errorf("Check(%q) found = %v, want %v", tt.text, found, tt.want)
+		}
+	}
+}
+
+func TestNewFilterMissingFile(t *testing.T) {
+	f := NewFilter(&config.Config{Sensitive: config.SensitiveConf{Words: []string{"配置词"}, File: filepath.Join(t.TempDir(), "missing.txt")}})
+	if _, _, found := f.Check("配置词"); !found {
+		t.Error("词库文件不可用时应使用配置中的词")
+	}
+}
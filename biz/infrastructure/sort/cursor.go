@@ -1,8 +1,12 @@
 package sort
 
 import (
+	"context"
+	"github.com/bytedance/gopkg/cloud/metainfo"
 	"go.mongodb.org/mongo-driver/bson"
 	"math"
+	gosort "sort"
+	"strconv"
 )
 
 type (
@@ -19,6 +23,71 @@ var (
 	TimeCursorType = (*TimeCursor)(nil)
 )
 
+// 评论列表排序模式
+const (
+	NewestSortMode int64 = iota
+	HotSortMode
+)
+
+const (
+	// SortModeMetaKey 客户端通过 kitex metainfo 传递评论列表排序模式时使用的 key
+	SortModeMetaKey = "COMMENT_SORT_MODE"
+	// ReplySortModeMetaKey 评论块中回复预览的排序模式
+	ReplySortModeMetaKey = "REPLY_SORT_MODE"
+)
+
+// SortModeFromContext 读取请求携带的排序模式，未携带或无法解析时按最新排序
+func SortModeFromContext(ctx context.Context) int64 {
+	return modeFromContext(ctx, SortModeMetaKey)
+}
+
+// ReplySortModeFromContext 读取请求携带的回复预览排序模式，未携带或无法解析时按最新排序
+func ReplySortModeFromContext(ctx context.Context) int64 {
+	return modeFromContext(ctx, ReplySortModeMetaKey)
+}
+
+func modeFromContext(ctx context.Context, key string) int64 {
+	value, ok := metainfo.GetValue(ctx, key)
+	if !ok {
+		value, _ = metainfo.GetPersistentValue(ctx, key)
+	}
+	mode, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return NewestSortMode
+	}
+	return mode
+}
+
+// CommentCursorType 返回排序模式对应的游标类型，未知模式按最新排序
+func CommentCursorType(sortMode int64) MongoCursor {
+	switch sortMode {
+	case HotSortMode:
+		return HeatCursorType
+	default:
+		return TimeCursorType
+	}
+}
+
+// OrderedSort 将游标生成的排序条件转为有序文档，_id 只用于区分并列的评论，排在最后
+// bson.M 编码时键的顺序不固定，多个排序字段时必须使用有序文档
+func OrderedSort(sort bson.M) bson.D {
+	keys := make([]string, 0, len(sort))
+	for key := range sort {
+		if key != "_id" {
+			keys = append(keys, key)
+		}
+	}
+	gosort.Strings(keys)
+	if _, ok := sort["_id"]; ok {
+		keys = append(keys, "_id")
+	}
+	d := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		d = append(d, bson.E{Key: key, Value: sort[key]})
+	}
+	return d
+}
+
 func (s *TimeCursor) MakeSortOptions(filter bson.M, backward bool) (bson.M, error) {
 	//构造lastId
 	var sortTime int64
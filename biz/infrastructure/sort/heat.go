@@ -0,0 +1,89 @@
+package sort
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"time"
+)
+
+const (
+	// heatEpoch 热度计算的起始时间（2024-01-01 UTC），单位秒
+	heatEpoch = 1704067200
+	// heatDecay 每过 heatDecay 秒，评论需要十倍的互动量才能保持相同热度
+	heatDecay = 45000
+	// replyWeight 每条回复贡献的互动量
+	replyWeight = 1
+	// reactionWeight 每个表态贡献的互动量
+	reactionWeight = 1
+)
+
+// HeatCursor 按热度排序的游标，热度相同的评论以 _id 排序，避免翻页时重复或遗漏
+// 未记录 ID 的旧游标只按热度比较
+type HeatCursor struct {
+	HeatValue float64 `json:"heatValue"`
+	ID        string  `json:"_id"`
+}
+
+var (
+	HeatCursorType = (*HeatCursor)(nil)
+)
+
+// HeatValue 计算评论热度：log10(互动量) + 发布时间 / heatDecay
+// 时间项随发布时间单调递增，因此无需定期刷新存量评论即可实现按时间衰减
+func HeatValue(count, reactions int64, createAt time.Time) float64 {
+	engagement := float64(count*replyWeight + reactions*reactionWeight)
+	return math.Log10(math.Max(engagement, 1)) + (float64(createAt.UnixMilli())/1000-heatEpoch)/heatDecay
+}
+
+// reactionsExpr 对 reactions 中各类表态数量求和
+var reactionsExpr = bson.M{"$sum": bson.M{"$map": bson.M{
+	"input": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$reactions", bson.M{}}}},
+	"in":    "$$this.v",
+}}}
+
+// HeatExpr 返回与 HeatValue 等价的 mongo 聚合表达式，用于在更新计数时原子地重算热度
+func HeatExpr() bson.M {
+	return bson.M{"$add": bson.A{
+		bson.M{"$log10": bson.M{"$max": bson.A{
+			bson.M{"$add": bson.A{
+				bson.M{"$multiply": bson.A{bson.M{"$ifNull": bson.A{"$count", 0}}, replyWeight}},
+				bson.M{"$multiply": bson.A{reactionsExpr, reactionWeight}},
+			}},
+			1,
+		}}},
+		bson.M{"$divide": bson.A{
+			bson.M{"$subtract": bson.A{bson.M{"$divide": bson.A{bson.M{"$toLong": "$createAt"}, 1000}}, heatEpoch}},
+			heatDecay,
+		}},
+	}}
+}
+
+func (s *HeatCursor) MakeSortOptions(filter bson.M, backward bool) (bson.M, error) {
+	op, order := "$lt", -1
+	if backward {
+		op, order = "$gt", 1
+	}
+	sort := bson.M{"heatValue": order, "_id": order}
+	if s == nil {
+		filter["heatValue"] = bson.M{op: math.Inf(-order)}
+		return sort, nil
+	}
+	if s.ID == "" {
+		filter["heatValue"] = bson.M{op: s.HeatValue}
+		return sort, nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(s.ID)
+	if err != nil {
+		return nil, err
+	}
+	// heatValue 的范围条件用于命中索引，$or 中再按 _id 区分热度相同的评论
+	filter["heatValue"] = bson.M{op + "e": s.HeatValue}
+	and, _ := filter["$and"].(bson.A)
+	filter["$and"] = append(and, bson.M{"$or": bson.A{
+		bson.M{"heatValue": bson.M{op: s.HeatValue}},
+		bson.M{"heatValue": s.HeatValue, "_id": bson.M{op: id}},
+	}})
+	return sort, nil
+}
@@ -0,0 +1,205 @@
+package sort
+
+import (
+	"context"
+	"github.com/CloudStriver/go-pkg/utils/pagination"
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHeatValue(t *testing.T) {
+	epoch := time.Unix(heatEpoch, 0)
+	tests := []struct {
+		name      string
+		count     int64
+		reactions int64
+		createAt  time.Time
+		want      float64
+	}{
+		{name: "无互动", createAt: epoch, want: 0},
+		{name: "负数互动按 1 计算", count: -3, createAt: epoch, want: 0},
+		{name: "回复与表态合计", count: 4, reactions: 6, createAt: epoch, want: 1},
+		{name: "时间衰减", reactions: 100, createAt: epoch.Add(heatDecay * time.Second), want: 3},
+		{name: "早于起始时间", createAt: epoch.Add(-heatDecay * time.Second), want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HeatValue(tt.count, tt.reactions, tt.createAt); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("HeatValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeatValueOrder(t *testing.T) {
+	now := time.Now()
+	// 互动量相差十倍的评论，发布时间相差 heatDecay 秒时热度相同
+	older := HeatValue(1000, 0, now.Add(-heatDecay*time.Second))
+	newer := HeatValue(100, 0, now)
+	if math.Abs(older-newer) > 1e-9 {
+		t.Errorf("older = %v, newer = %v", older, newer)
+	}
+	if HeatValue(10, 0, now) <= HeatValue(9, 0, now) {
+		t.Error("互动更多的评论热度应更高")
+	}
+}
+
+func TestHeatCursorMakeSortOptions(t *testing.T) {
+	id := primitive.NewObjectID()
+	tests := []struct {
+		name       string
+		cursor     *HeatCursor
+		backward   bool
+		wantFilter bson.M
+		wantSort   bson.M
+	}{
+		{
+			name:       "首页",
+			wantFilter: bson.M{"heatValue": bson.M{"$lt": math.Inf(1)}},
+			wantSort:   bson.M{"heatValue": -1, "_id": -1},
+		},
+		{
+			name:       "反向首页",
+			backward:   true,
+			wantFilter: bson.M{"heatValue": bson.M{"$gt": math.Inf(-1)}},
+			wantSort:   bson.M{"heatValue": 1, "_id": 1},
+		},
+		{
+			name:       "旧游标只按热度比较",
+			cursor:     &HeatCursor{HeatValue: 1.5},
+			wantFilter: bson.M{"heatValue": bson.M{"$lt": 1.5}},
+			wantSort:   bson.M{"heatValue": -1, "_id": -1},
+		},
+		{
+			name:   "热度相同时按 _id 继续",
+			cursor: &HeatCursor{HeatValue: 1.5, ID: id.Hex()},
+			wantFilter: bson.M{
+				"heatValue": bson.M{"$lte": 1.5},
+				"$and": bson.A{bson.M{"$or": bson.A{
+					bson.M{"heatValue": bson.M{"$lt": 1.5}},
+					bson.M{"heatValue": 1.5, "_id": bson.M{"$lt": id}},
+				}}},
+			},
+			wantSort: bson.M{"heatValue": -1, "_id": -1},
+		},
+		{
+			name:     "反向翻页",
+			cursor:   &HeatCursor{HeatValue: 1.5, ID: id.Hex()},
+			backward: true,
+			wantFilter: bson.M{
+				"heatValue": bson.M{"$gte": 1.5},
+				"$and": bson.A{bson.M{"$or": bson.A{
+					bson.M{"heatValue": bson.M{"$gt": 1.5}},
+					bson.M{"heatValue": 1.5, "_id": bson.M{"$gt": id}},
+				}}},
+			},
+			wantSort: bson.M{"heatValue": 1, "_id": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := bson.M{}
+			sort, err := tt.cursor.MakeSortOptions(filter, tt.backward)
+			if err != nil {
+				t.Fatalf("MakeSortOptions() error = %v", err)
+			}
+			if !reflect.DeepEqual(filter, tt.wantFilter) {
+				t.Errorf("filter = %v, want %v", filter, tt.wantFilter)
+			}
+			if !reflect.DeepEqual(sort, tt.wantSort) {
+				t.Errorf("sort = %v, want %v", sort, tt.wantSort)
+			}
+		})
+	}
+}
+
+func TestHeatCursorToken(t *testing.T) {
+	type comment struct {
+		ID        primitive.ObjectID
+		HeatValue float64
+	}
+	first, last := comment{ID: primitive.NewObjectID(), HeatValue: 2}, comment{ID: primitive.NewObjectID(), HeatValue: 1}
+	store := pagination.NewRawStore(HeatCursorType)
+	token, err := store.StoreCursor(context.Background(), nil, first, last)
+	if err != nil {
+		t.Fatalf("StoreCursor() error = %v", err)
+	}
+	if err = store.LoadCursor(context.Background(), *token, false); err != nil {
+		t.Fatalf("LoadCursor() error = %v", err)
+	}
+	want := &HeatCursor{HeatValue: 1, ID: last.ID.Hex()}
+	if got := store.GetCursor(); !reflect.DeepEqual(got, want) {
+		t.Errorf("cursor = %v, want %v", got, want)
+	}
+}
+
+func TestHeatCursorKeepsExistingAnd(t *testing.T) {
+	filter := bson.M{"$and": bson.A{bson.M{"state": 1}}}
+	if _, err := (&HeatCursor{HeatValue: 1, ID: primitive.NewObjectID().Hex()}).MakeSortOptions(filter, false); err != nil {
+		t.Fatalf("MakeSortOptions() error = %v", err)
+	}
+	if and := filter["$and"].(bson.A); len(and) != 2 {
+		t.Errorf("$and = %v, want 2 conditions", and)
+	}
+}
+
+func TestHeatCursorInvalidId(t *testing.T) {
+	if _, err := (&HeatCursor{HeatValue: 1, ID: "invalid"}).MakeSortOptions(bson.M{}, false); err == nil {
+		t.Error("MakeSortOptions() error = nil, want invalid id error")
+	}
+}
+
+func TestOrderedSort(t *testing.T) {
+	tests := []struct {
+		name string
+		sort bson.M
+		want bson.D
+	}{
+		{name: "单个字段", sort: bson.M{"sortTime": -1}, want: bson.D{{Key: "sortTime", Value: -1}}},
+		{name: "_id 排在最后", sort: bson.M{"_id": 1, "heatValue": 1}, want: bson.D{{Key: "heatValue", Value: 1}, {Key: "_id", Value: 1}}},
+		{name: "只有 _id", sort: bson.M{"_id": -1}, want: bson.D{{Key: "_id", Value: -1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OrderedSort(tt.sort); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OrderedSort() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortModeFromContext(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want int64
+	}{
+		{name: "未携带", ctx: context.Background(), want: NewestSortMode},
+		{name: "最热", ctx: metainfo.WithValue(context.Background(), SortModeMetaKey, "1"), want: HotSortMode},
+		{name: "持久化传递", ctx: metainfo.WithPersistentValue(context.Background(), SortModeMetaKey, "1"), want: HotSortMode},
+		{name: "无法解析", ctx: metainfo.WithValue(context.Background(), SortModeMetaKey, "hot"), want: NewestSortMode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SortModeFromContext(tt.ctx); got != tt.want {
+				t.Errorf("SortModeFromContext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplySortModeFromContext(t *testing.T) {
+	ctx := metainfo.WithValue(context.Background(), SortModeMetaKey, "1")
+	if got := ReplySortModeFromContext(ctx); got != NewestSortMode {
+		t.Errorf("列表排序不应影响回复排序: got %v", got)
+	}
+	ctx = metainfo.WithValue(ctx, ReplySortModeMetaKey, "1")
+	if got := ReplySortModeFromContext(ctx); got != HotSortMode {
+		t.Errorf("ReplySortModeFromContext() = %v, want %v", got, HotSortMode)
+	}
+}
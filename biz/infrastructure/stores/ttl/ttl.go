@@ -0,0 +1,48 @@
+package ttl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// ErrInvalidRetention 保留时长不足一秒，expireAfterSeconds 为 0 时文档写入后会被立即删除
+var ErrInvalidRetention = errors.New("TTL 索引的保留时长不能少于一秒")
+
+// EnsureIndex 在 field 上创建文档保留 retention 后自动删除的 TTL 索引
+// 索引已存在但保留时长不同时通过 collMod 修改，避免重新创建时出现 IndexOptionsConflict
+func EnsureIndex(ctx context.Context, coll *mongo.Collection, field string, retention time.Duration) error {
+	seconds := int32(retention.Seconds())
+	if seconds <= 0 {
+		return ErrInvalidRetention
+	}
+	name := field + "_1"
+	specs, err := coll.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if spec.Name != name {
+			continue
+		}
+		if spec.ExpireAfterSeconds == nil {
+			return fmt.Errorf("索引[%s]已存在且不是 TTL 索引", name)
+		}
+		if *spec.ExpireAfterSeconds == seconds {
+			return nil
+		}
+		return coll.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: coll.Name()},
+			{Key: "index", Value: bson.D{{Key: "name", Value: name}, {Key: "expireAfterSeconds", Value: seconds}}},
+		}).Err()
+	}
+	_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(seconds),
+	})
+	return err
+}
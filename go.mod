@@ -6,6 +6,7 @@ require (
 	github.com/CloudStriver/cloudmind-mq v1.0.12-0.20240406130428-3a00c4159388
 	github.com/CloudStriver/go-pkg v0.0.0-20240206060942-84060a3dd273
 	github.com/CloudStriver/service-idl-gen-go v0.0.0-20240415104627-bc25298e4fd0
+	github.com/bytedance/gopkg v0.0.0-20231219111115-a5eedbe96960
 	github.com/bytedance/sonic v1.10.2
 	github.com/cloudwego/kitex v0.8.0
 	github.com/elastic/go-elasticsearch/v8 v8.11.1
@@ -14,6 +15,7 @@ require (
 	github.com/mitchellh/mapstructure v1.1.2
 	github.com/neo4j/neo4j-go-driver/v5 v5.19.0
 	github.com/samber/lo v1.39.0
+	github.com/segmentio/kafka-go v0.4.38
 	github.com/zeromicro/go-queue v1.1.8
 	github.com/zeromicro/go-zero v1.6.1
 	go.mongodb.org/mongo-driver v1.13.1
@@ -29,7 +31,6 @@ require (
 	github.com/apache/thrift v0.16.0 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/bufbuild/protocompile v0.7.1 // indirect
-	github.com/bytedance/gopkg v0.0.0-20231219111115-a5eedbe96960 // indirect
 	github.com/cenkalti/backoff/v4 v4.2.1 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/chenzhuoyu/base64x v0.0.0-20230717121745-296ad89f973d // indirect
@@ -77,7 +78,6 @@ require (
 	github.com/prometheus/client_model v0.4.1-0.20230718164431-9a2bf3000d16 // indirect
 	github.com/prometheus/common v0.44.0 // indirect
 	github.com/prometheus/procfs v0.11.1 // indirect
-	github.com/segmentio/kafka-go v0.4.38 // indirect
 	github.com/spaolacci/murmur3 v1.1.0 // indirect
 	github.com/stretchr/testify v1.8.4 // indirect
 	github.com/tidwall/gjson v1.14.4 // indirect
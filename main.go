@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"github.com/CloudStriver/go-pkg/utils/kitex/middleware"
 	"github.com/CloudStriver/go-pkg/utils/util/log"
+	"github.com/CloudStriver/platform/biz/adaptor"
+	"github.com/CloudStriver/platform/biz/application/service"
 	"github.com/CloudStriver/platform/provider"
 	"github.com/CloudStriver/service-idl-gen-go/kitex_gen/platform/platformservice"
 	"github.com/cloudwego/kitex/pkg/klog"
@@ -10,14 +14,64 @@ import (
 	"github.com/cloudwego/kitex/server"
 	"github.com/kitex-contrib/obs-opentelemetry/tracing"
 	"net"
+	"net/http"
+)
+
+var (
+	reconcile = flag.Bool("reconcile", false, "校对评论区与一级评论的计数后退出")
+	subjectId = flag.String("subject", "", "只校对指定的评论区")
+	repair    = flag.Bool("repair", false, "修正校对出的计数偏差")
+	batchSize = flag.Int64("batch", 100, "全量校对时每批处理的评论区数量，级联删除时每批删除的评论数量")
+
+	deleteSubject = flag.String("delete-subject", "", "级联删除指定的评论区及其全部评论后退出")
+	dryRun        = flag.Bool("dry-run", false, "级联删除时只统计将要删除的评论，不做修改")
+
+	ensureIndexes = flag.Bool("ensure-indexes", false, "创建各集合的查询索引后退出")
+	backfillHeat  = flag.Bool("backfill-heat", false, "为缺少热度的历史评论计算热度后退出")
 )
 
 func main() {
+	flag.Parse()
 	klog.SetLogger(log.NewKlogLogger())
 	s, err := provider.NewPlatformServerImpl()
 	if err != nil {
 		panic(err)
 	}
+	if *reconcile {
+		runReconcile(s)
+		return
+	}
+	if *deleteSubject != "" {
+		runDeleteSubject(s)
+		return
+	}
+	if *ensureIndexes {
+		if err = s.IndexService.EnsureIndexes(context.Background()); err != nil {
+			panic(err)
+		}
+		log.Info("索引创建完成")
+		return
+	}
+	if *backfillHeat {
+		updated, err := s.ReconcileService.BackfillHeat(context.Background())
+		if err != nil {
+			panic(err)
+		}
+		log.Info("热度补齐完成: 评论[%d]", updated)
+		return
+	}
+	go s.RecycleService.RunPurge(context.Background())
+	go s.OutboxService.RunDispatch(context.Background())
+	go s.SubjectService.RunCascade(context.Background())
+	go s.SensitiveFilter.Watch(context.Background())
+	if s.Stream.ListenOn != "" {
+		go s.StreamService.RunPoll(context.Background())
+		go runStream(s)
+	}
+	if s.Gateway.ListenOn != "" {
+		go runGateway(s)
+	}
+
 	addr, err := net.ResolveTCPAddr("tcp", s.ListenOn)
 	if err != nil {
 		panic(err)
@@ -36,3 +90,45 @@ func main() {
 		log.Error(err.Error())
 	}
 }
+
+func runStream(s *adaptor.PlatformServerImpl) {
+	if err := http.ListenAndServe(s.Stream.ListenOn, adaptor.NewStreamHandler(s)); err != nil {
+		log.Error("评论变更推送服务退出: %v", err)
+	}
+}
+
+func runGateway(s *adaptor.PlatformServerImpl) {
+	if err := http.ListenAndServe(s.Gateway.ListenOn, adaptor.NewGatewayHandler(s)); err != nil {
+		log.Error("HTTP 接口服务退出: %v", err)
+	}
+}
+
+func runReconcile(s *adaptor.PlatformServerImpl) {
+	var (
+		err    error
+		report *service.ReconcileReport
+	)
+	ctx := context.Background()
+	if *subjectId != "" {
+		report, err = s.ReconcileService.ReconcileSubject(ctx, *subjectId, *repair)
+	} else {
+		report, err = s.ReconcileService.ReconcileAll(ctx, *batchSize, *repair)
+	}
+	if err != nil {
+		panic(err)
+	}
+	for _, d := range report.Drifts {
+		log.Info("评论区[%s] 评论[%s] %s: 存储值[%d] 实际值[%d]", d.SubjectId, d.CommentId, d.Field, d.Stored, d.Actual)
+	}
+	log.Info("校对完成: 评论区[%d] 偏差[%d] 已修正[%v]", report.Subjects, len(report.Drifts), report.Repaired)
+}
+
+func runDeleteSubject(s *adaptor.PlatformServerImpl) {
+	p, err := s.SubjectService.DeleteSubjectCascade(context.Background(), *deleteSubject, *batchSize, *dryRun, func(p *service.DeleteSubjectProgress) {
+		log.Info("评论区[%s] 第[%d]批 评论[%d/%d] 最后处理[%s]", p.SubjectId, p.Batches, p.Deleted, p.Total, p.LastId)
+	})
+	if err != nil {
+		panic(err)
+	}
+	log.Info("级联删除完成: 评论区[%s] 评论[%d] 试运行[%v]", p.SubjectId, p.Deleted, p.DryRun)
+}
@@ -3,11 +3,21 @@ package provider
 import (
 	"github.com/CloudStriver/platform/biz/application/service"
 	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/event"
+	"github.com/CloudStriver/platform/biz/infrastructure/idempotent"
 	"github.com/CloudStriver/platform/biz/infrastructure/kq"
+	"github.com/CloudStriver/platform/biz/infrastructure/limiter"
+	changeModel "github.com/CloudStriver/platform/biz/infrastructure/mapper/change"
 	commentModel "github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
 	labelModel "github.com/CloudStriver/platform/biz/infrastructure/mapper/label"
+	moderationModel "github.com/CloudStriver/platform/biz/infrastructure/mapper/moderation"
+	outboxModel "github.com/CloudStriver/platform/biz/infrastructure/mapper/outbox"
+	reactionModel "github.com/CloudStriver/platform/biz/infrastructure/mapper/reaction"
+	recycleModel "github.com/CloudStriver/platform/biz/infrastructure/mapper/recycle"
 	"github.com/CloudStriver/platform/biz/infrastructure/mapper/relation"
+	revisionModel "github.com/CloudStriver/platform/biz/infrastructure/mapper/revision"
 	subjectModel "github.com/CloudStriver/platform/biz/infrastructure/mapper/subject"
+	"github.com/CloudStriver/platform/biz/infrastructure/sensitive"
 	"github.com/CloudStriver/platform/biz/infrastructure/stores/redis"
 	"github.com/google/wire"
 )
@@ -22,20 +32,37 @@ var ApplicationSet = wire.NewSet(
 	service.SubjectSet,
 	service.LabelSet,
 	service.RelationSet,
+	service.ReconcileSet,
+	service.RecycleSet,
+	service.OutboxSet,
+	service.StreamSet,
+	service.IndexSet,
 )
 
 var InfrastructureSet = wire.NewSet(
 	config.NewConfig,
 	redis.NewRedis,
 	kq.NewDeleteCommentRelationKq,
+	kq.NewCommentMentionKq,
+	sensitive.NewFilter,
+	limiter.NewLimiter,
+	idempotent.NewStore,
+	event.NewBus,
 	MapperSet,
 )
 
 var MapperSet = wire.NewSet(
 	commentModel.NewMongoMapper,
+	commentModel.NewEsMapper,
 	subjectModel.NewMongoMapper,
 	labelModel.NewMongoMapper,
 	labelModel.NewEsMapper,
 	relation.NewNeo4jMapper,
 	relation.NewMongoMapper,
+	revisionModel.NewMongoMapper,
+	recycleModel.NewMongoMapper,
+	moderationModel.NewMongoMapper,
+	reactionModel.NewMongoMapper,
+	outboxModel.NewMongoMapper,
+	changeModel.NewMongoMapper,
 )
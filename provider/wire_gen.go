@@ -10,11 +10,21 @@ import (
 	"github.com/CloudStriver/platform/biz/adaptor"
 	"github.com/CloudStriver/platform/biz/application/service"
 	"github.com/CloudStriver/platform/biz/infrastructure/config"
+	"github.com/CloudStriver/platform/biz/infrastructure/event"
+	"github.com/CloudStriver/platform/biz/infrastructure/idempotent"
 	"github.com/CloudStriver/platform/biz/infrastructure/kq"
+	"github.com/CloudStriver/platform/biz/infrastructure/limiter"
+	"github.com/CloudStriver/platform/biz/infrastructure/mapper/change"
 	"github.com/CloudStriver/platform/biz/infrastructure/mapper/comment"
 	"github.com/CloudStriver/platform/biz/infrastructure/mapper/label"
+	"github.com/CloudStriver/platform/biz/infrastructure/mapper/moderation"
+	"github.com/CloudStriver/platform/biz/infrastructure/mapper/outbox"
+	"github.com/CloudStriver/platform/biz/infrastructure/mapper/reaction"
+	"github.com/CloudStriver/platform/biz/infrastructure/mapper/recycle"
 	"github.com/CloudStriver/platform/biz/infrastructure/mapper/relation"
+	"github.com/CloudStriver/platform/biz/infrastructure/mapper/revision"
 	"github.com/CloudStriver/platform/biz/infrastructure/mapper/subject"
+	"github.com/CloudStriver/platform/biz/infrastructure/sensitive"
 	"github.com/CloudStriver/platform/biz/infrastructure/stores/redis"
 )
 
@@ -26,38 +36,114 @@ func NewPlatformServerImpl() (*adaptor.PlatformServerImpl, error) {
 		return nil, err
 	}
 	iMongoMapper := comment.NewMongoMapper(configConfig)
+	iEsMapper := comment.NewEsMapper(configConfig)
 	subjectIMongoMapper := subject.NewMongoMapper(configConfig)
-	deleteCommentRelationKq := kq.NewDeleteCommentRelationKq(configConfig)
+	filter := sensitive.NewFilter(configConfig)
+	redisRedis := redis.NewRedis(configConfig)
+	limiterLimiter := limiter.NewLimiter(redisRedis)
+	store := idempotent.NewStore(configConfig, redisRedis)
+	revisionIMongoMapper := revision.NewMongoMapper(configConfig)
+	recycleIMongoMapper := recycle.NewMongoMapper(configConfig)
+	moderationIMongoMapper := moderation.NewMongoMapper(configConfig)
+	reactionIMongoMapper := reaction.NewMongoMapper(configConfig)
+	outboxIMongoMapper := outbox.NewMongoMapper(configConfig)
+	changeIMongoMapper := change.NewMongoMapper(configConfig)
+	commentMentionKq := kq.NewCommentMentionKq(configConfig)
+	bus := event.NewBus(configConfig)
 	commentService := &service.CommentService{
-		CommentMongoMapper:      iMongoMapper,
-		SubjectMongoMapper:      subjectIMongoMapper,
-		DeleteCommentRelationKq: deleteCommentRelationKq,
+		Config:                configConfig,
+		CommentMongoMapper:    iMongoMapper,
+		CommentEsMapper:       iEsMapper,
+		SubjectMongoMapper:    subjectIMongoMapper,
+		SensitiveFilter:       filter,
+		Limiter:               limiterLimiter,
+		Idempotent:            store,
+		RevisionMongoMapper:   revisionIMongoMapper,
+		RecycleMongoMapper:    recycleIMongoMapper,
+		ModerationMongoMapper: moderationIMongoMapper,
+		ReactionMongoMapper:   reactionIMongoMapper,
+		OutboxMongoMapper:     outboxIMongoMapper,
+		ChangeMongoMapper:     changeIMongoMapper,
+		CommentMentionKq:      commentMentionKq,
+		Bus:                   bus,
 	}
-	iEsMapper := label.NewEsMapper(configConfig)
+	labelIEsMapper := label.NewEsMapper(configConfig)
 	labelIMongoMapper := label.NewMongoMapper(configConfig)
 	labelService := &service.LabelService{
-		LabelEsMapper:    iEsMapper,
+		LabelEsMapper:    labelIEsMapper,
 		LabelMongoMapper: labelIMongoMapper,
 	}
 	subjectService := &service.SubjectService{
-		SubjectMongoMapper:      subjectIMongoMapper,
-		DeleteSubjectRelationKq: deleteCommentRelationKq,
+		Config:                configConfig,
+		SubjectMongoMapper:    subjectIMongoMapper,
+		CommentMongoMapper:    iMongoMapper,
+		CommentEsMapper:       iEsMapper,
+		RevisionMongoMapper:   revisionIMongoMapper,
+		ReactionMongoMapper:   reactionIMongoMapper,
+		OutboxMongoMapper:     outboxIMongoMapper,
+		RecycleMongoMapper:    recycleIMongoMapper,
+		ModerationMongoMapper: moderationIMongoMapper,
+		ChangeMongoMapper:     changeIMongoMapper,
 	}
-	redisRedis := redis.NewRedis(configConfig)
 	relationNeo4jMapper := relation.NewNeo4jMapper(configConfig)
 	relationIMongoMapper := relation.NewMongoMapper(configConfig)
 	relationServiceImpl := &service.RelationServiceImpl{
 		Config:              configConfig,
 		Redis:               redisRedis,
+		Idempotent:          store,
 		RelationModel:       relationNeo4jMapper,
 		RelationMongoMapper: relationIMongoMapper,
 	}
+	reconcileService := &service.ReconcileService{
+		CommentMongoMapper: iMongoMapper,
+		SubjectMongoMapper: subjectIMongoMapper,
+	}
+	recycleService := &service.RecycleService{
+		Config:              configConfig,
+		CommentMongoMapper:  iMongoMapper,
+		CommentEsMapper:     iEsMapper,
+		SubjectMongoMapper:  subjectIMongoMapper,
+		RecycleMongoMapper:  recycleIMongoMapper,
+		RevisionMongoMapper: revisionIMongoMapper,
+		ReactionMongoMapper: reactionIMongoMapper,
+		OutboxMongoMapper:   outboxIMongoMapper,
+		ChangeMongoMapper:   changeIMongoMapper,
+		Bus:                 bus,
+	}
+	deleteCommentRelationKq := kq.NewDeleteCommentRelationKq(configConfig)
+	outboxService := &service.OutboxService{
+		Config:                  configConfig,
+		OutboxMongoMapper:       outboxIMongoMapper,
+		DeleteCommentRelationKq: deleteCommentRelationKq,
+	}
+	streamService := &service.StreamService{
+		Config:             configConfig,
+		Bus:                bus,
+		SubjectMongoMapper: subjectIMongoMapper,
+		CommentMongoMapper: iMongoMapper,
+		ChangeMongoMapper:  changeIMongoMapper,
+	}
+	indexService := &service.IndexService{
+		CommentMongoMapper:    iMongoMapper,
+		ReactionMongoMapper:   reactionIMongoMapper,
+		OutboxMongoMapper:     outboxIMongoMapper,
+		ChangeMongoMapper:     changeIMongoMapper,
+		RecycleMongoMapper:    recycleIMongoMapper,
+		RevisionMongoMapper:   revisionIMongoMapper,
+		ModerationMongoMapper: moderationIMongoMapper,
+	}
 	platformServerImpl := &adaptor.PlatformServerImpl{
-		Config:          configConfig,
-		CommentService:  commentService,
-		LabelService:    labelService,
-		SubjectService:  subjectService,
-		RelationService: relationServiceImpl,
+		Config:           configConfig,
+		CommentService:   commentService,
+		LabelService:     labelService,
+		SubjectService:   subjectService,
+		RelationService:  relationServiceImpl,
+		ReconcileService: reconcileService,
+		RecycleService:   recycleService,
+		OutboxService:    outboxService,
+		StreamService:    streamService,
+		IndexService:     indexService,
+		SensitiveFilter:  filter,
 	}
 	return platformServerImpl, nil
 }